@@ -3,7 +3,6 @@ package balancer
 import (
 	"errors"
 	"github.com/classtorch/prpc/resolver"
-	"strings"
 )
 
 var (
@@ -11,19 +10,19 @@ var (
 	BalancerNotExistErr   = errors.New("special balancer not exist")
 )
 
-var (
-	m = make(map[string]Builder)
-)
-
+// Get get a balancer Builder by name from the default Registry
 func Get(name string) Builder {
-	if builder, ok := m[name]; ok {
-		return builder
-	}
-	return nil
+	return defaultRegistry.Get(name)
 }
 
+// Register register a balancer Builder to the default Registry
 func Register(builder Builder) {
-	m[strings.ToLower(builder.Name())] = builder
+	defaultRegistry.Register(builder)
+}
+
+// Unregister remove a balancer Builder from the default Registry
+func Unregister(name string) {
+	defaultRegistry.Unregister(name)
 }
 
 // Builder creates a balancer.
@@ -0,0 +1,18 @@
+package base
+
+import (
+	"github.com/classtorch/prpc/balancer"
+)
+
+// NewErrPicker returns a Picker that always returns err on Pick().
+func NewErrPicker(err error) balancer.Picker {
+	return &errPicker{err: err}
+}
+
+type errPicker struct {
+	err error // Pick() always returns this err.
+}
+
+func (p *errPicker) Pick() (balancer.PickResult, error) {
+	return balancer.PickResult{}, p.err
+}
@@ -0,0 +1,51 @@
+package balancer
+
+import (
+	"strings"
+	"sync"
+)
+
+var (
+	defaultRegistry = NewRegistry()
+)
+
+// Registry is a set of balancer Builders indexed by lower case name, it is safe for concurrent use.
+// A Registry can be passed to a ClientConn to isolate its balancers from the global ones.
+type Registry struct {
+	mu sync.RWMutex
+	m  map[string]Builder
+}
+
+// NewRegistry return an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{m: make(map[string]Builder)}
+}
+
+// DefaultRegistry return the global Registry used by Register, Unregister and Get
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// Register register a balancer Builder, a Builder with the same name is replaced
+func (r *Registry) Register(builder Builder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.m[strings.ToLower(builder.Name())] = builder
+}
+
+// Unregister remove the balancer Builder of name
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.m, strings.ToLower(name))
+}
+
+// Get get a balancer Builder by name, return nil if not exist
+func (r *Registry) Get(name string) Builder {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if builder, ok := r.m[strings.ToLower(name)]; ok {
+		return builder
+	}
+	return nil
+}
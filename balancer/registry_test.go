@@ -0,0 +1,74 @@
+package balancer
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// namedBuilder a Builder of name building nothing
+type namedBuilder string
+
+func (b namedBuilder) Build() Balancer {
+	return nil
+}
+
+func (b namedBuilder) Name() string {
+	return string(b)
+}
+
+func Test_Registry(t *testing.T) {
+	r := NewRegistry()
+	if b := r.Get("weighted"); b != nil {
+		t.Fatalf("expect:nil,but get:%v", b)
+	}
+	r.Register(namedBuilder("Weighted"))
+	// the names are case insensitive
+	if b := r.Get("weighted"); b != namedBuilder("Weighted") {
+		t.Fatalf("expect:Weighted,but get:%v", b)
+	}
+	r.Unregister("WEIGHTED")
+	if b := r.Get("Weighted"); b != nil {
+		t.Fatalf("expect:nil after Unregister,but get:%v", b)
+	}
+}
+
+func Test_RegistryIsolation(t *testing.T) {
+	a, b := NewRegistry(), NewRegistry()
+	a.Register(namedBuilder("registry-a"))
+	if got := b.Get("registry-a"); got != nil {
+		t.Fatalf("expect:nil in the other registry,but get:%v", got)
+	}
+	if got := Get("registry-a"); got != nil {
+		t.Fatalf("expect:nil in the default registry,but get:%v", got)
+	}
+	Register(namedBuilder("registry-default"))
+	defer Unregister("registry-default")
+	if got := a.Get("registry-default"); got != nil {
+		t.Fatalf("expect:nil in a new registry,but get:%v", got)
+	}
+	if got := DefaultRegistry().Get("registry-default"); got != namedBuilder("registry-default") {
+		t.Fatalf("expect:registry-default,but get:%v", got)
+	}
+}
+
+func Test_RegistryConcurrency(t *testing.T) {
+	r := NewRegistry()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := "balancer" + strconv.Itoa(i)
+			for j := 0; j < 100; j++ {
+				r.Register(namedBuilder(name))
+				if b := r.Get(name); b == nil {
+					t.Errorf("expect:%s,but get:nil", name)
+				}
+				r.Get("balancer0")
+				r.Unregister(name)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"github.com/classtorch/prpc/grpc"
 	"github.com/classtorch/prpc/http"
+	"github.com/classtorch/prpc/logger"
+	"github.com/classtorch/prpc/metrics"
 	grpcRaw "google.golang.org/grpc"
 )
 
@@ -15,10 +17,49 @@ type ClientConnInterface interface {
 	NewStream(ctx context.Context, desc *grpcRaw.StreamDesc, method string, opts ...grpc.CallOption) (grpcRaw.ClientStream, error)
 }
 
+// HttpStreamClientConnInterface a ClientConnInterface invoking http server-streaming methods,
+// it is kept apart so the implementations of ClientConnInterface are not broken.
+// Unlike the unary calls, the streams don't go through the interceptors given by WithUnaryInterceptor,
+// so they are neither recorded by WithMetricsRecorder nor traced by the otel interceptors,
+// the outgoing metadata of the context is still sent as http headers.
+type HttpStreamClientConnInterface interface {
+	ClientConnInterface
+	HttpNewStream(ctx context.Context, method string, api string, req interface{}, opts ...http.CallOption) (grpcRaw.ClientStream, error)
+}
+
+var _ HttpStreamClientConnInterface = (*ClientConn)(nil)
+
 // ClientConn is pRPC ClientConn
 type ClientConn struct {
-	httpConn *http.ClientConn
-	grpcConn *grpcRaw.ClientConn
+	httpConn   *http.ClientConn
+	grpcConn   *grpcRaw.ClientConn
+	connOption connectOption
+}
+
+// connectOption pRPC ClientConn Option
+type connectOption struct {
+	// interceptors of the http and grpc unary calls, the first one is the outermost
+	unaryInterceptors []UnaryInterceptor
+	metrics           metrics.MetricsRecorder
+	log               logger.Logger
+}
+
+type Option func(*connectOption)
+
+// WithUnaryInterceptor add interceptors installed on both the http and the grpc ClientConn,
+// they run before the interceptors given to NewHttpClientConn and NewGrpcClientConn
+func WithUnaryInterceptor(interceptors ...UnaryInterceptor) Option {
+	return func(o *connectOption) {
+		o.unaryInterceptors = append(o.unaryInterceptors, interceptors...)
+	}
+}
+
+// WithLogger log the http and the grpc ClientConn with log, the WithLog and WithLogger options given to
+// NewHttpClientConn and NewGrpcClientConn take precedence
+func WithLogger(log logger.Logger) Option {
+	return func(o *connectOption) {
+		o.log = log
+	}
 }
 
 func (cc *ClientConn) GetHttpConn() *http.ClientConn {
@@ -29,11 +70,27 @@ func (cc *ClientConn) GetGrpcConn() *grpcRaw.ClientConn {
 	return cc.grpcConn
 }
 
-func NewClientConn() *ClientConn {
-	return &ClientConn{}
+func NewClientConn(opts ...Option) *ClientConn {
+	cc := &ClientConn{}
+	for _, option := range opts {
+		option(&cc.connOption)
+	}
+	return cc
 }
 
 func (cc *ClientConn) NewGrpcClientConn(ctx context.Context, target string, opts ...grpc.ConnOption) error {
+	if interceptor := chainUnaryInterceptors(cc.connOption.unaryInterceptors); interceptor != nil {
+		opts = append([]grpc.ConnOption{
+			grpc.WithUnaryInterceptor(GrpcUnaryInterceptor(interceptor)),
+			grpc.WithOptions(grpcRaw.WithStatsHandler(GrpcAttemptStatsHandler())),
+		}, opts...)
+	}
+	if cc.connOption.metrics != nil {
+		opts = append([]grpc.ConnOption{grpc.WithMetricsRecorder(cc.connOption.metrics)}, opts...)
+	}
+	if cc.connOption.log != nil {
+		opts = append([]grpc.ConnOption{grpc.WithLogger(cc.connOption.log)}, opts...)
+	}
 	grpcConn, err := grpc.NewClientConn(ctx, target, opts...)
 	if err != nil {
 		return err
@@ -43,6 +100,15 @@ func (cc *ClientConn) NewGrpcClientConn(ctx context.Context, target string, opts
 }
 
 func (cc *ClientConn) NewHttpClientConn(ctx context.Context, target string, opts ...http.ConnOption) error {
+	if interceptor := chainUnaryInterceptors(cc.connOption.unaryInterceptors); interceptor != nil {
+		opts = append([]http.ConnOption{http.WithInterceptor(HttpInterceptor(interceptor))}, opts...)
+	}
+	if cc.connOption.metrics != nil {
+		opts = append([]http.ConnOption{http.WithMetricsRecorder(cc.connOption.metrics)}, opts...)
+	}
+	if cc.connOption.log != nil {
+		opts = append([]http.ConnOption{http.WithLogger(cc.connOption.log)}, opts...)
+	}
 	httpConn, err := http.NewClientConn(ctx, target, opts...)
 	if err != nil {
 		return err
@@ -51,6 +117,20 @@ func (cc *ClientConn) NewHttpClientConn(ctx context.Context, target string, opts
 	return nil
 }
 
+// Close close the underlying http and grpc ClientConn
+func (cc *ClientConn) Close() error {
+	var err error
+	if cc.httpConn != nil {
+		err = cc.httpConn.Close()
+	}
+	if cc.grpcConn != nil {
+		if grpcErr := cc.grpcConn.Close(); err == nil {
+			err = grpcErr
+		}
+	}
+	return err
+}
+
 // HttpInvoke http invoke
 func (cc *ClientConn) HttpInvoke(ctx context.Context, method string, api string, req interface{}, reply interface{}, opts ...http.CallOption) error {
 	if cc.httpConn == nil {
@@ -82,3 +162,15 @@ func (cc *ClientConn) NewStream(ctx context.Context, desc *grpcRaw.StreamDesc, m
 	}
 	return cc.grpcConn.NewStream(ctx, desc, method, convertToRawGrpcCallOption(opts...)...)
 }
+
+// HttpNewStream http server-streaming invoke
+func (cc *ClientConn) HttpNewStream(ctx context.Context, method string, api string, req interface{}, opts ...http.CallOption) (grpcRaw.ClientStream, error) {
+	if cc.httpConn == nil {
+		return nil, errors.New("httpClientConn empty, please init it")
+	}
+	stream, err := cc.httpConn.NewStream(ctx, method, api, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
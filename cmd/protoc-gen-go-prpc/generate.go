@@ -210,22 +210,18 @@ func genService(gen *protogen.Plugin, file *protogen.File, g *protogen.Generated
 	g.P("}")
 	g.P()
 
-	var methodIndex, streamIndex int
+	var streamIndex int
 	// Client method implementations.
 	for _, method := range service.Methods {
 		if checkIsHttp(method) {
 			genHttpClientMethod(g, method)
-			if httpGenerateGrpc == nil || !*httpGenerateGrpc {
-				continue
-			}
 		}
-		if !method.Desc.IsStreamingServer() && !method.Desc.IsStreamingClient() {
-			// Unary RPC method
-			genGrpcClientMethod(gen, file, g, method, methodIndex)
-			methodIndex++
-		} else {
-			// Streaming RPC method
+		if !checkIsHttp(method) || *httpGenerateGrpc {
 			genGrpcClientMethod(gen, file, g, method, streamIndex)
+		}
+		if method.Desc.IsStreamingServer() || method.Desc.IsStreamingClient() {
+			// Stream auxiliary types shared by the http and grpc methods
+			genClientStreamTypes(g, method)
 			streamIndex++
 		}
 	}
@@ -284,7 +280,11 @@ func genService(gen *protogen.Plugin, file *protogen.File, g *protogen.Generated
 	helper.generateServerFunctions(gen, file, g, service, serverType, serviceDescVar)
 }
 
+// checkIsHttp whether the method has a http rule, client-streaming methods are grpc only
 func checkIsHttp(method *protogen.Method) bool {
+	if method.Desc.IsStreamingClient() {
+		return false
+	}
 	rule, ok := proto.GetExtension(method.Desc.Options(), annotations.E_Http).(*annotations.HttpRule)
 	if rule != nil && ok {
 		return true
@@ -314,12 +314,21 @@ func clientSignatureGrpc(g *protogen.GeneratedFile, method *protogen.Method) str
 	return s
 }
 
-func clientSignatureHttp(g *protogen.GeneratedFile, method *protogen.Method) string {
-	funcName := method.GoName
+// clientMethodName return the name of the http client method
+func clientMethodName(method *protogen.Method) string {
 	if checkIsHttp(method) && *httpGenerateGrpc {
-		funcName = "Http" + funcName
+		return "Http" + method.GoName
+	}
+	return method.GoName
+}
+
+func clientSignatureHttp(g *protogen.GeneratedFile, method *protogen.Method) string {
+	funcName := clientMethodName(method)
+	output := "*" + g.QualifiedGoIdent(method.Output.GoIdent)
+	if method.Desc.IsStreamingServer() {
+		output = method.Parent.GoName + "_" + method.GoName + "Client"
 	}
-	return fmt.Sprintf("%s (ctx %s, in *%s, opts ...%s) (*%s,error)", funcName, g.QualifiedGoIdent(contextPackage.Ident("Context")), g.QualifiedGoIdent(method.Input.GoIdent), g.QualifiedGoIdent(prpcHttpPackage.Ident("CallOption")), g.QualifiedGoIdent(method.Output.GoIdent))
+	return fmt.Sprintf("%s (ctx %s, in *%s, opts ...%s) (%s,error)", funcName, g.QualifiedGoIdent(contextPackage.Ident("Context")), g.QualifiedGoIdent(method.Input.GoIdent), g.QualifiedGoIdent(prpcHttpPackage.Ident("CallOption")), output)
 }
 
 func getHttpRule(method *protogen.Method) (string, string, map[string]string) {
@@ -365,7 +374,9 @@ func genHttpClientMethod(g *protogen.GeneratedFile, method *protogen.Method) {
 	service := method.Parent
 	httpApiPath, httpMethod, customerHttpHeadMap := getHttpRule(method)
 	g.P("func (c *", unexport(service.GoName), "Client) ", clientSignatureHttp(g, method), "{")
-	g.P("out := new(", method.Output.GoIdent, ")")
+	if fileFields := getFileFields(method); *httpBytesAsFile && httpMethod != "GET" && len(fileFields) > 0 {
+		g.P("opts = append([]", prpcHttpPackage.Ident("CallOption"), "{", prpcHttpPackage.Ident("WithMultipart"), "(", strings.Join(fileFields, ", "), ")}, opts...)")
+	}
 	if len(customerHttpHeadMap) > 0 {
 		headerName := "header"
 		g.P(headerName, " := map[string]string{")
@@ -375,6 +386,23 @@ func genHttpClientMethod(g *protogen.GeneratedFile, method *protogen.Method) {
 		g.P("}")
 		g.P("opts = ", prpcHttpPackage.Ident("CallOptions(opts)"), ".CombineHeader(", headerName, ")")
 	}
+	if method.Desc.IsStreamingServer() {
+		// server-streaming over text/event-stream or application/x-ndjson
+		// ClientConnInterface doesn't carry HttpNewStream, see prpc.HttpStreamClientConnInterface
+		g.P("sc, ok := c.cc.(", prpcPackage.Ident("HttpStreamClientConnInterface"), ")")
+		g.P("if !ok {")
+		g.P("return nil, ", statusPackage.Ident("Errorf"), "(", codesPackage.Ident("Unimplemented"), `, "method `, clientMethodName(method), ` needs a ClientConn implementing HttpStreamClientConnInterface")`)
+		g.P("}")
+		g.P("stream, err := sc.HttpNewStream(ctx, ", "\""+httpMethod+"\"", ",", "\""+httpApiPath+"\"", ", in, opts...)")
+		g.P("if err != nil {")
+		g.P("return nil, err")
+		g.P("}")
+		g.P("return &", unexport(service.GoName)+method.GoName+"Client", "{stream}, nil")
+		g.P("}")
+		g.P()
+		return
+	}
+	g.P("out := new(", method.Output.GoIdent, ")")
 	g.P("err := c.cc.HttpInvoke(ctx, ", "\""+httpMethod+"\"", ",", "\""+httpApiPath+"\"", ", in, out, opts...)")
 	g.P("if err != nil {")
 	g.P("return nil, err")
@@ -384,6 +412,17 @@ func genHttpClientMethod(g *protogen.GeneratedFile, method *protogen.Method) {
 	g.P()
 }
 
+// getFileFields return the quoted json names of the singular bytes fields of the method input
+func getFileFields(method *protogen.Method) []string {
+	var fileFields []string
+	for _, field := range method.Input.Fields {
+		if field.Desc.Kind() == protoreflect.BytesKind && !field.Desc.IsList() && (field.Oneof == nil || field.Oneof.Desc.IsSynthetic()) {
+			fileFields = append(fileFields, strconv.Quote(string(field.Desc.Name())))
+		}
+	}
+	return fileFields
+}
+
 func genGrpcClientMethod(gen *protogen.Plugin, file *protogen.File, g *protogen.GeneratedFile, method *protogen.Method, index int) {
 	service := method.Parent
 	sname := helper.formatFullMethodName(service, method)
@@ -413,7 +452,12 @@ func genGrpcClientMethod(gen *protogen.Plugin, file *protogen.File, g *protogen.
 	g.P("return x, nil")
 	g.P("}")
 	g.P()
+}
 
+// genClientStreamTypes generates the stream auxiliary types and methods of the client
+func genClientStreamTypes(g *protogen.GeneratedFile, method *protogen.Method) {
+	service := method.Parent
+	streamType := unexport(service.GoName) + method.GoName + "Client"
 	genSend := method.Desc.IsStreamingClient()
 	genRecv := method.Desc.IsStreamingServer()
 	genCloseAndRecv := !method.Desc.IsStreamingServer()
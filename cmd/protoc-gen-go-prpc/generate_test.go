@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files of testdata")
+
+// streamFile a proto file with a unary and a server-streaming http method and a grpc only server-streaming method
+func streamFile() *descriptorpb.FileDescriptorProto {
+	httpRule := func(rule *annotations.HttpRule) *descriptorpb.MethodOptions {
+		options := &descriptorpb.MethodOptions{}
+		proto.SetExtension(options, annotations.E_Http, rule)
+		return options
+	}
+	message := func(name string) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{
+			Name: proto.String(name),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("id"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				JsonName: proto.String("id"),
+			}},
+		}
+	}
+	return &descriptorpb.FileDescriptorProto{
+		Name:        proto.String("stream/stream.proto"),
+		Package:     proto.String("stream"),
+		Syntax:      proto.String("proto3"),
+		Options:     &descriptorpb.FileOptions{GoPackage: proto.String("example.com/stream;stream")},
+		MessageType: []*descriptorpb.DescriptorProto{message("Request"), message("Event")},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Watcher"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{
+					Name:       proto.String("Get"),
+					InputType:  proto.String(".stream.Request"),
+					OutputType: proto.String(".stream.Event"),
+					Options:    httpRule(&annotations.HttpRule{Pattern: &annotations.HttpRule_Get{Get: "/events/get"}}),
+				},
+				{
+					Name:            proto.String("Watch"),
+					InputType:       proto.String(".stream.Request"),
+					OutputType:      proto.String(".stream.Event"),
+					ServerStreaming: proto.Bool(true),
+					Options:         httpRule(&annotations.HttpRule{Pattern: &annotations.HttpRule_Post{Post: "/events/watch"}}),
+				},
+				{
+					Name:            proto.String("Tail"),
+					InputType:       proto.String(".stream.Request"),
+					OutputType:      proto.String(".stream.Event"),
+					ServerStreaming: proto.Bool(true),
+				},
+			},
+		}},
+	}
+}
+
+// generate run the plugin on file and return the content of the generated file
+func generate(t *testing.T, file *descriptorpb.FileDescriptorProto) []byte {
+	t.Helper()
+	gen, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	generateFileGrpc(gen, gen.FilesByPath[file.GetName()])
+	resp := gen.Response()
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	if len(resp.File) != 1 {
+		t.Fatalf("expect:1 generated file,but get:%d", len(resp.File))
+	}
+	return []byte(resp.File[0].GetContent())
+}
+
+// uploadFile a proto file with http methods whose requests have bytes fields
+func uploadFile() *descriptorpb.FileDescriptorProto {
+	httpRule := func(rule *annotations.HttpRule) *descriptorpb.MethodOptions {
+		options := &descriptorpb.MethodOptions{}
+		proto.SetExtension(options, annotations.E_Http, rule)
+		return options
+	}
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     typ.Enum(),
+			JsonName: proto.String(name),
+		}
+	}
+	// only the singular bytes fields outside of a oneof are sent as files
+	chunks := field("chunks", 4, descriptorpb.FieldDescriptorProto_TYPE_BYTES)
+	chunks.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	inline := field("inline", 5, descriptorpb.FieldDescriptorProto_TYPE_BYTES)
+	inline.OneofIndex = proto.Int32(0)
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("upload/upload.proto"),
+		Package: proto.String("upload"),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptorpb.FileOptions{GoPackage: proto.String("example.com/upload;upload")},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("UploadRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+					field("content", 2, descriptorpb.FieldDescriptorProto_TYPE_BYTES),
+					field("thumb", 3, descriptorpb.FieldDescriptorProto_TYPE_BYTES),
+					chunks,
+					inline,
+				},
+				OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String("source")}},
+			},
+			{
+				Name:  proto.String("UploadReply"),
+				Field: []*descriptorpb.FieldDescriptorProto{field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING)},
+			},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Uploader"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{
+					Name:       proto.String("Upload"),
+					InputType:  proto.String(".upload.UploadRequest"),
+					OutputType: proto.String(".upload.UploadReply"),
+					Options:    httpRule(&annotations.HttpRule{Pattern: &annotations.HttpRule_Post{Post: "/files"}, Body: "*"}),
+				},
+				{
+					Name:       proto.String("Check"),
+					InputType:  proto.String(".upload.UploadRequest"),
+					OutputType: proto.String(".upload.UploadReply"),
+					Options:    httpRule(&annotations.HttpRule{Pattern: &annotations.HttpRule_Get{Get: "/files/check"}}),
+				},
+			},
+		}},
+	}
+}
+
+// checkGolden compare got with the golden file name of testdata, it is updated with the -update flag
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	golden := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expect:the content of %s,but get:\n%s", golden, got)
+	}
+}
+
+func Test_GenerateServerStreaming(t *testing.T) {
+	requireUnimplemented = proto.Bool(true)
+	checkGolden(t, "stream_prpc.pb.go.golden", generate(t, streamFile()))
+}
+
+func Test_GenerateBytesAsFile(t *testing.T) {
+	requireUnimplemented = proto.Bool(true)
+	*httpBytesAsFile = true
+	defer func() {
+		*httpBytesAsFile = false
+	}()
+	checkGolden(t, "upload_prpc.pb.go.golden", generate(t, uploadFile()))
+}
@@ -10,6 +10,7 @@ import (
 var showVersion = flag.Bool("version", false, "print the version and exit")
 var requireUnimplemented *bool
 var httpGenerateGrpc = flag.Bool("http_generate_grpc", true, "set whether http needs to generate grpc methods")
+var httpBytesAsFile = flag.Bool("http_bytes_as_file", false, "set whether the bytes fields of http request bodies are sent as multipart/form-data files")
 
 const version = "v1.0.1"
 
@@ -11,16 +11,23 @@ import (
 // adaptGrpcBalancerName is the name of balancer_for_adapt_grpc balancer.
 const adaptGrpcBalancerName = "balancer_for_adapt_grpc"
 
-//RegisterBalancer, register a balancer to gRPC
-func RegisterBalancer(picker *wrapper.PickerWrapper) string {
-	builder := base.NewBalancerBuilder(adaptGrpcBalancerName, &rrPickerBuilder{pickerWrapper: picker}, base.Config{HealthCheck: true})
-	gBalancer.Register(builder)
+var registerOnce sync.Once
+
+// pickerWrapperKey is the address BalancerAttributes key of the pRPC PickerWrapper of the ClientConn
+type pickerWrapperKey struct{}
+
+//RegisterBalancer, register a balancer to gRPC once, it is shared by all ClientConns
+//which hand their pRPC PickerWrapper over in the addresses BalancerAttributes
+func RegisterBalancer() string {
+	registerOnce.Do(func() {
+		builder := base.NewBalancerBuilder(adaptGrpcBalancerName, &rrPickerBuilder{}, base.Config{HealthCheck: true})
+		gBalancer.Register(builder)
+	})
 	return adaptGrpcBalancerName
 }
 
 // rrPicker,implementation of gPRC base balancer PickerBuilder
 type rrPickerBuilder struct {
-	pickerWrapper *wrapper.PickerWrapper
 }
 
 // gRPC Build, Build a gRPC Picker
@@ -28,8 +35,16 @@ func (pb *rrPickerBuilder) Build(info base.PickerBuildInfo) gBalancer.Picker {
 	if len(info.ReadySCs) == 0 {
 		return base.NewErrPicker(gBalancer.ErrNoSubConnAvailable)
 	}
+	var pickerWrapper *wrapper.PickerWrapper
+	for _, subInfo := range info.ReadySCs {
+		pickerWrapper, _ = subInfo.Address.BalancerAttributes.Value(pickerWrapperKey{}).(*wrapper.PickerWrapper)
+		break
+	}
+	if pickerWrapper == nil {
+		return base.NewErrPicker(errors.New("pRPC picker wrapper not found"))
+	}
 	return &rrPicker{
-		pickerWrapper: pb.pickerWrapper,
+		pickerWrapper: pickerWrapper,
 		readySCs:      info.ReadySCs,
 	}
 }
@@ -3,28 +3,32 @@ package adapter
 import (
 	"context"
 	pResolver "github.com/classtorch/prpc/resolver"
+	"github.com/classtorch/prpc/wrapper"
 	"google.golang.org/grpc/attributes"
 	gResolver "google.golang.org/grpc/resolver"
 )
 
 // resolverBuilder, implementation of gPRC resolver Builder
 type resolverBuilder struct {
-	notice *Notice
+	notice        *Notice
+	pickerWrapper *wrapper.PickerWrapper
 }
 
 // Notice struct used for communication between pPRC and gRPC's Resolver
 type Notice struct {
 	UpdateState chan pResolver.State //from pRPC resolver UpdateState event, trigger gRPC resolver UpdateState
+	ReportError chan error           //from pRPC resolver ReportError event, trigger gRPC resolver ReportError
 	Close       chan struct{}        //from gRPC resolver Close event, trigger pRPC resolver Close
 	ResolveNow  chan struct{}        //from gRPC resolver ResolveNow event, trigger pRPC resolver ResolveNow
 	Ctx         context.Context
 	Cancel      context.CancelFunc
 }
 
-// new gRPC Resolver Builder
-func NewResolverBuilder(notice *Notice) *resolverBuilder {
+// new gRPC Resolver Builder, pickerWrapper is handed over to the adapt balancer with the addresses
+func NewResolverBuilder(notice *Notice, pickerWrapper *wrapper.PickerWrapper) *resolverBuilder {
 	return &resolverBuilder{
-		notice: notice,
+		notice:        notice,
+		pickerWrapper: pickerWrapper,
 	}
 }
 
@@ -36,12 +40,15 @@ func (tb *resolverBuilder) Build(target gResolver.Target, cc gResolver.ClientCon
 	}, nil
 }
 
-// watch pRPC resolver UpdateState change event,then invoke gRPC resolver UpdateState method
+// watch pRPC resolver UpdateState and ReportError event,then invoke gRPC resolver UpdateState and ReportError method
 func (tb *resolverBuilder) watchPRpcResolver(cc gResolver.ClientConn) {
 	for {
 		select {
 		case state := <-tb.notice.UpdateState:
-			cc.UpdateState(buildGrpcState(state))
+			cc.UpdateState(buildGrpcState(state, tb.pickerWrapper))
+			break
+		case err := <-tb.notice.ReportError:
+			cc.ReportError(err)
 			break
 		case _ = <-tb.notice.Ctx.Done():
 			return
@@ -54,14 +61,15 @@ func (tb *resolverBuilder) Scheme() string {
 }
 
 // convert pPRC State to gRPC State
-func buildGrpcState(stat pResolver.State) gResolver.State {
+func buildGrpcState(stat pResolver.State, pickerWrapper *wrapper.PickerWrapper) gResolver.State {
 	grpcAddresses := make([]gResolver.Address, len(stat.Addresses))
 	attributesInfo := &attributes.Attributes{}
+	balancerAttributes := attributes.New(pickerWrapperKey{}, pickerWrapper)
 	for idx, address := range stat.Addresses {
 		for key, value := range address.Attributes {
 			attributesInfo = attributesInfo.WithValue(key, value)
 		}
-		grpcAddresses[idx] = gResolver.Address{Addr: address.Addr, Attributes: attributesInfo}
+		grpcAddresses[idx] = gResolver.Address{Addr: address.Addr, Attributes: attributesInfo, BalancerAttributes: balancerAttributes}
 	}
 	return gResolver.State{
 		Addresses:  grpcAddresses,
@@ -76,10 +84,16 @@ type resolver struct {
 
 // gRPC Resolver ResolveNow event notice pRPC
 func (tr *resolver) ResolveNow(gResolver.ResolveNowOptions) {
-	tr.tb.notice.ResolveNow <- struct{}{}
+	select {
+	case tr.tb.notice.ResolveNow <- struct{}{}:
+	case <-tr.tb.notice.Ctx.Done():
+	}
 }
 
 // gRPC Resolver Close event notice pRPC
 func (tr *resolver) Close() {
-	tr.tb.notice.Close <- struct{}{}
+	select {
+	case tr.tb.notice.Close <- struct{}{}:
+	case <-tr.tb.notice.Ctx.Done():
+	}
 }
@@ -7,11 +7,11 @@ import (
 	"github.com/classtorch/prpc/balancer/roundrobin"
 	"github.com/classtorch/prpc/grpc/adapter"
 	"github.com/classtorch/prpc/logger"
+	"github.com/classtorch/prpc/metrics"
 	"github.com/classtorch/prpc/resolver"
 	"github.com/classtorch/prpc/wrapper"
 	"google.golang.org/grpc"
-	"log"
-	"os"
+	gResolver "google.golang.org/grpc/resolver"
 	"sync"
 )
 
@@ -25,16 +25,31 @@ type ClientConn struct {
 	balancerWrapper *wrapper.CCBalancerWrapper
 	pickerWrapper   *wrapper.PickerWrapper
 	notice          *adapter.Notice
+	// pending the resolver events not yet sent to gRPC, wake signals the sender there are some
+	pending []resolverEvent
+	wake    chan struct{}
+}
+
+// resolverEvent a state, or an error if err is not nil, of the pRPC resolver
+type resolverEvent struct {
+	state resolver.State
+	err   error
 }
 
 // connectOption pRPC's grpc ClientConn connect Option
 type connectOption struct {
-	grpcOpts      []grpc.DialOption
-	resolvers     []resolver.Builder
-	balancerBuild balancer.Builder
+	grpcOpts         []grpc.DialOption
+	resolvers        []resolver.Builder
+	resolverRegistry *resolver.Registry
+	balancerBuild    balancer.Builder
+	balancerRegistry *balancer.Registry
 	// selected balancer name
 	curBalancerName string
-	log             logger.Log
+	log             logger.Logger
+	// interceptors chained in order, the first one is the outermost
+	unaryInterceptors  []grpc.UnaryClientInterceptor
+	streamInterceptors []grpc.StreamClientInterceptor
+	metrics            metrics.MetricsRecorder
 }
 
 type CallOption struct {
@@ -44,7 +59,7 @@ type CallOption struct {
 func defaultConnectOption() connectOption {
 	return connectOption{
 		curBalancerName: roundrobin.Name,
-		log:             logger.NewDefaultLogger(log.New(os.Stderr, logger.DefaultLogPrefix, log.LstdFlags)),
+		log:             logger.Default(),
 	}
 }
 
@@ -62,13 +77,69 @@ func WithBalancerName(name string) ConnOption {
 	}
 }
 
+// WithOptions add grpc DialOptions, it can be given several times
 func WithOptions(grpcOpts ...grpc.DialOption) ConnOption {
 	return func(o *connectOption) {
-		o.grpcOpts = grpcOpts
+		o.grpcOpts = append(o.grpcOpts, grpcOpts...)
+	}
+}
+
+// WithUnaryInterceptor add unary interceptors, the interceptors of all the WithUnaryInterceptor options
+// are chained in order, the first one is the outermost
+func WithUnaryInterceptor(interceptors ...grpc.UnaryClientInterceptor) ConnOption {
+	return func(o *connectOption) {
+		o.unaryInterceptors = append(o.unaryInterceptors, interceptors...)
 	}
 }
 
+// WithStreamInterceptor add stream interceptors, the interceptors of all the WithStreamInterceptor options
+// are chained in order, the first one is the outermost
+func WithStreamInterceptor(interceptors ...grpc.StreamClientInterceptor) ConnOption {
+	return func(o *connectOption) {
+		o.streamInterceptors = append(o.streamInterceptors, interceptors...)
+	}
+}
+
+// dialOptions return the grpc DialOptions, the interceptors are chained after the ones given by WithOptions
+func (o *connectOption) dialOptions() []grpc.DialOption {
+	grpcOpts := append([]grpc.DialOption(nil), o.grpcOpts...)
+	if len(o.unaryInterceptors) > 0 {
+		grpcOpts = append(grpcOpts, grpc.WithChainUnaryInterceptor(o.unaryInterceptors...))
+	}
+	if len(o.streamInterceptors) > 0 {
+		grpcOpts = append(grpcOpts, grpc.WithChainStreamInterceptor(o.streamInterceptors...))
+	}
+	return grpcOpts
+}
+
+// WithResolverRegistry look up resolver Builders not given by WithResolver in registry instead of the default one
+func WithResolverRegistry(registry *resolver.Registry) ConnOption {
+	return func(o *connectOption) {
+		o.resolverRegistry = registry
+	}
+}
+
+// WithBalancerRegistry look up the balancer Builder in registry instead of the default one
+func WithBalancerRegistry(registry *balancer.Registry) ConnOption {
+	return func(o *connectOption) {
+		o.balancerRegistry = registry
+	}
+}
+
+// WithMetricsRecorder record the picks and the ready addresses of the ClientConn to recorder
+func WithMetricsRecorder(recorder metrics.MetricsRecorder) ConnOption {
+	return func(o *connectOption) {
+		o.metrics = recorder
+	}
+}
+
+// WithLog log with log, see WithLogger for a structured logger
 func WithLog(log logger.Log) ConnOption {
+	return WithLogger(logger.FromLog(log))
+}
+
+// WithLogger log with log instead of logger.Default, the logs are named grpc and have the target as field
+func WithLogger(log logger.Logger) ConnOption {
 	return func(o *connectOption) {
 		o.log = log
 	}
@@ -83,35 +154,48 @@ func NewClientConn(ctx context.Context, target string, opts ...ConnOption) (*grp
 	for _, option := range opts {
 		option(&cc.connOption)
 	}
-	parseTarget := resolver.ParseTarget(target)
+	cc.connOption.log = cc.connOption.log.Named("grpc").With("target", target)
+	parseTarget, err := resolver.ParseTarget(target)
+	if err != nil {
+		return nil, err
+	}
 	scheme := parseTarget.Scheme
 	if scheme == resolver.GetPassThroughScheme() {
-		return grpc.DialContext(ctx, target, cc.connOption.grpcOpts...)
+		return grpc.DialContext(ctx, target, cc.connOption.dialOptions()...)
 	}
 	cc.parseTarget = parseTarget
+	resolverBuild := cc.getResolverBuilder(scheme)
+	if resolverBuild == nil {
+		// targets such as "unix:/tmp/s.sock" or "dns:///host:port" are resolved by gRPC itself
+		if gResolver.Get(scheme) != nil {
+			return grpc.DialContext(ctx, target, cc.connOption.dialOptions()...)
+		}
+		return nil, resolver.ResolverNotExistErr
+	}
 
-	pickerWrapper := wrapper.NewPickerWrapper(cc.connOption.log)
+	pickerWrapper := wrapper.NewPickerWrapperWithLogger(cc.connOption.log.Named("balancer"))
+	if cc.connOption.metrics != nil {
+		pickerWrapper.SetMetricsRecorder(parseTarget.Endpoint, cc.connOption.metrics)
+	}
 	cc.pickerWrapper = pickerWrapper
-	balancerWrapper, err := wrapper.GetBalancerWrapper(cc.connOption.curBalancerName, pickerWrapper)
+	balancerWrapper, err := wrapper.GetBalancerWrapper(cc.connOption.balancerRegistry, cc.connOption.curBalancerName, pickerWrapper)
 	if err != nil {
 		return nil, err
 	}
 	cc.balancerWrapper = balancerWrapper
 
-	resolverBuild := cc.getResolverBuilder(cc.parseTarget.Scheme)
-	if resolverBuild == nil {
-		return nil, resolver.ResolverNotExistErr
-	}
-
 	noticeCtx, cancel := context.WithCancel(context.Background())
 	cc.notice = &adapter.Notice{
 		UpdateState: make(chan resolver.State),
+		ReportError: make(chan error),
 		Close:       make(chan struct{}),
 		ResolveNow:  make(chan struct{}),
 		Ctx:         noticeCtx,
 		Cancel:      cancel,
 	}
+	cc.wake = make(chan struct{}, 1)
 	go cc.watchGrpcResolver()
+	go cc.sendResolverEvents()
 
 	resolverWrapper, err := wrapper.NewCCResolverWrapper(cc, resolverBuild)
 	if err != nil {
@@ -119,7 +203,7 @@ func NewClientConn(ctx context.Context, target string, opts ...ConnOption) (*grp
 	}
 	cc.resolverWrapper = resolverWrapper
 
-	grpcClientConn, err := newGrpcClientConn(ctx, cc.notice, pickerWrapper, parseTarget, cc.connOption.grpcOpts)
+	grpcClientConn, err := newGrpcClientConn(ctx, cc.notice, pickerWrapper, parseTarget, cc.connOption.dialOptions())
 	if err != nil {
 		// if happen err,need to cancel notice,let watch goroutine exit,this is very important
 		cc.notice.Cancel()
@@ -130,12 +214,15 @@ func NewClientConn(ctx context.Context, target string, opts ...ConnOption) (*grp
 
 // newGrpcClientConn new grpc ClientConn
 func newGrpcClientConn(ctx context.Context, notice *adapter.Notice, pickerWrapper *wrapper.PickerWrapper, target resolver.Target, options []grpc.DialOption) (*grpc.ClientConn, error) {
-	adaptResolverBuilder := adapter.NewResolverBuilder(notice)
-	adaptBalancerName := adapter.RegisterBalancer(pickerWrapper)
+	adaptResolverBuilder := adapter.NewResolverBuilder(notice, pickerWrapper)
+	adaptBalancerName := adapter.RegisterBalancer()
 	grpcOpts := options
 	grpcOpts = append(grpcOpts, grpc.WithResolvers(adaptResolverBuilder),
 		grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingPolicy":"%s"}`, adaptBalancerName)))
 	newTarget := fmt.Sprintf("%s://%s/%s", adaptResolverBuilder.Scheme(), target.Agent, target.Endpoint)
+	if len(target.URL.RawQuery) > 0 {
+		newTarget += "?" + target.URL.RawQuery
+	}
 	return grpc.DialContext(
 		ctx,
 		newTarget,
@@ -149,7 +236,10 @@ func (cc *ClientConn) watchGrpcResolver() {
 	for {
 		select {
 		case _ = <-cc.notice.Close:
+			// gRPC closed its resolver with the ClientConn, stop forwarding the pRPC resolver events
 			cc.resolverWrapper.Close()
+			cc.notice.Cancel()
+			return
 		case _ = <-cc.notice.ResolveNow:
 			cc.resolverWrapper.ResolveNow()
 		case _ = <-cc.notice.Ctx.Done():
@@ -158,6 +248,12 @@ func (cc *ClientConn) watchGrpcResolver() {
 	}
 }
 
+// ResolverBuilder return the resolver Builder of scheme, the meta-resolvers such as failover
+// build their children with the resolvers of the ClientConn
+func (cc *ClientConn) ResolverBuilder(scheme string) resolver.Builder {
+	return cc.getResolverBuilder(scheme)
+}
+
 // getResolverBuilder return resolver Builder
 func (cc *ClientConn) getResolverBuilder(scheme string) resolver.Builder {
 	for _, rb := range cc.connOption.resolvers {
@@ -165,22 +261,71 @@ func (cc *ClientConn) getResolverBuilder(scheme string) resolver.Builder {
 			return rb
 		}
 	}
+	if cc.connOption.resolverRegistry != nil {
+		return cc.connOption.resolverRegistry.Get(scheme)
+	}
 	return resolver.Get(scheme)
 }
 
 // UpdateResolverState call pPRC balancer UpdateState and notice gRPC
-// please note that notifying gRPC updateState opens a goroutine, and it will block if the goroutine is not used,
-// because UpdateState channel not ready to receive，more specifically, the adapter watchPRpcResolver method is not executed
+// the events are queued and sent to gRPC in order by sendResolverEvents, UpdateResolverState doesn't block
+// if the adapter watchPRpcResolver method is not executed yet
+// resolver errors are forwarded to gRPC as well, both sides keep the last known good addresses
 func (cc *ClientConn) UpdateResolverState(state resolver.State, err error) error {
 	cc.mu.Lock()
 	defer cc.mu.Unlock()
-	cc.balancerWrapper.UpdateState(state)
-	go func() {
-		cc.notice.UpdateState <- state
-	}()
+	if err != nil {
+		cc.connOption.log.Error("resolver error, keep the last addresses", "err", err)
+		cc.balancerWrapper.ResolverError(err)
+		// a newer error replaces the one not sent yet
+		if n := len(cc.pending); n > 0 && cc.pending[n-1].err != nil {
+			cc.pending[n-1] = resolverEvent{err: err}
+		} else {
+			cc.pending = append(cc.pending, resolverEvent{err: err})
+		}
+	} else {
+		cc.balancerWrapper.UpdateState(state)
+		// a newer state replaces all the events not sent yet
+		cc.pending = append(cc.pending[:0], resolverEvent{state: state})
+	}
+	select {
+	case cc.wake <- struct{}{}:
+	default:
+	}
 	return nil
 }
 
+// sendResolverEvents send the pending resolver events to gRPC one by one in order,
+// it exits once the notice is canceled, e.g. the gRPC ClientConn is closed
+func (cc *ClientConn) sendResolverEvents() {
+	for {
+		select {
+		case <-cc.wake:
+		case <-cc.notice.Ctx.Done():
+			return
+		}
+		cc.mu.Lock()
+		events := cc.pending
+		cc.pending = nil
+		cc.mu.Unlock()
+		for _, event := range events {
+			if event.err != nil {
+				select {
+				case cc.notice.ReportError <- event.err:
+				case <-cc.notice.Ctx.Done():
+					return
+				}
+				continue
+			}
+			select {
+			case cc.notice.UpdateState <- event.state:
+			case <-cc.notice.Ctx.Done():
+				return
+			}
+		}
+	}
+}
+
 // GetParsedTarget return parsed target
 func (cc *ClientConn) GetParsedTarget() resolver.Target {
 	return cc.parseTarget
@@ -2,11 +2,24 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"github.com/classtorch/prpc/balancer/roundrobin"
+	"github.com/classtorch/prpc/grpc/adapter"
+	"github.com/classtorch/prpc/logger"
 	"github.com/classtorch/prpc/resolver"
+	"github.com/classtorch/prpc/wrapper"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 type mockResolverBuilder struct {
@@ -71,3 +84,172 @@ func Test_NewClientConn(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+// ccResolverBuilder hand over the ClientConn of the resolvers it builds
+type ccResolverBuilder struct {
+	ccs chan resolver.ClientConn
+}
+
+func (b ccResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn) (resolver.Resolver, error) {
+	b.ccs <- cc
+	return mockResolver{}, nil
+}
+
+func (b ccResolverBuilder) Scheme() string {
+	return "errors"
+}
+
+func Test_ResolverErrorAfterClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+	rb := ccResolverBuilder{ccs: make(chan resolver.ClientConn, 1)}
+	client, err := NewClientConn(context.Background(), "errors://127.0.0.1/account", WithResolver(rb),
+		WithLogger(logger.NopLogger{}), WithOptions(grpc.WithTransportCredentials(insecure.NewCredentials())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := <-rb.ccs
+	cc.ReportError(errors.New("consul unavailable"))
+	client.Close()
+
+	// the errors reported after the close must not leave goroutines behind, nor the watchers of the notice
+	for i := 0; i < 20; i++ {
+		cc.ReportError(errors.New("consul unavailable"))
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Fatalf("expect:at most %d goroutines,but get:%d", before, n)
+	}
+}
+
+func Test_ResolverEventsOrder(t *testing.T) {
+	balancerWrapper, err := wrapper.GetBalancerWrapper(nil, roundrobin.Name, wrapper.NewPickerWrapperWithLogger(logger.NopLogger{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cc := &ClientConn{
+		connOption:      connectOption{log: logger.NopLogger{}},
+		balancerWrapper: balancerWrapper,
+		wake:            make(chan struct{}, 1),
+		notice: &adapter.Notice{
+			UpdateState: make(chan resolver.State),
+			ReportError: make(chan error),
+			Ctx:         ctx,
+			Cancel:      cancel,
+		},
+	}
+	// a newer state replaces the events not sent yet, a newer error the error not sent yet
+	cc.UpdateResolverState(resolver.State{}, errors.New("consul unavailable"))
+	cc.UpdateResolverState(resolver.State{Addresses: []resolver.Address{{Addr: "127.0.0.1:33000"}}}, nil)
+	cc.UpdateResolverState(resolver.State{}, errors.New("consul unavailable"))
+	cc.UpdateResolverState(resolver.State{}, errors.New("consul timeout"))
+	if len(cc.pending) != 2 || cc.pending[0].state.Addresses[0].Addr != "127.0.0.1:33000" || cc.pending[1].err.Error() != "consul timeout" {
+		t.Fatalf("expect:the state and the last error,but get:%v", cc.pending)
+	}
+
+	go cc.sendResolverEvents()
+	for i := 0; i < 100; i++ {
+		addr := "127.0.0.1:" + strconv.Itoa(33000+i)
+		cc.UpdateResolverState(resolver.State{Addresses: []resolver.Address{{Addr: addr}}}, nil)
+		cc.UpdateResolverState(resolver.State{}, errors.New(addr))
+		// gRPC gets the state before the error reported after it
+		select {
+		case state := <-cc.notice.UpdateState:
+			if state.Addresses[0].Addr != addr {
+				t.Fatalf("expect:%v,but get:%v", addr, state.Addresses[0].Addr)
+			}
+		case err := <-cc.notice.ReportError:
+			t.Fatalf("expect:the state of %v first,but get:%v", addr, err)
+		}
+		if err := <-cc.notice.ReportError; err.Error() != addr {
+			t.Fatalf("expect:%v,but get:%v", addr, err)
+		}
+	}
+}
+
+func Test_Interceptors(t *testing.T) {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	defer server.Stop()
+
+	var calls []string
+	unary := func(name string) grpc.UnaryClientInterceptor {
+		return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			calls = append(calls, name+":"+method)
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+	}
+	stream := func(name string) grpc.StreamClientInterceptor {
+		return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			calls = append(calls, name+":"+method)
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+	}
+	dialer := func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	}
+	client, err := NewClientConn(context.Background(), "bufnet",
+		WithOptions(grpc.WithContextDialer(dialer)), WithOptions(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		WithUnaryInterceptor(unary("a"), unary("b")), WithUnaryInterceptor(unary("c")),
+		WithStreamInterceptor(stream("d")), WithStreamInterceptor(stream("e")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	healthClient := healthpb.NewHealthClient(client)
+	if _, err = healthClient.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	watch, err := healthClient.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = watch.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	expect := "a:/grpc.health.v1.Health/Check,b:/grpc.health.v1.Health/Check,c:/grpc.health.v1.Health/Check," +
+		"d:/grpc.health.v1.Health/Watch,e:/grpc.health.v1.Health/Watch"
+	if strings.Join(calls, ",") != expect {
+		t.Fatalf("expect:%v,but get:%v", expect, strings.Join(calls, ","))
+	}
+}
+
+func Test_GrpcResolvedTarget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "grpc.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	defer server.Stop()
+
+	// no pRPC resolver is registered for unix, the target is resolved by gRPC
+	client, err := NewClientConn(context.Background(), "unix:"+path, WithOptions(grpc.WithTransportCredentials(insecure.NewCredentials())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err = healthpb.NewHealthClient(client).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_TargetQuery(t *testing.T) {
+	client, err := NewClientConn(context.Background(), "consul://127.0.0.1:8500/account?dc=dc1", WithResolver(mockResolverBuilder{}),
+		WithLogger(logger.NopLogger{}), WithOptions(grpc.WithTransportCredentials(insecure.NewCredentials())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if !strings.HasSuffix(client.Target(), "/account?dc=dc1") {
+		t.Fatalf("expect:the query kept,but get:%v", client.Target())
+	}
+}
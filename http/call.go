@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/classtorch/prpc/pkg/query"
+	"github.com/classtorch/prpc/wrapper"
+	"io"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -15,83 +18,256 @@ var (
 	variableUrlRex = regexp.MustCompile(`{(.*?)}`)
 )
 
-type CallOption func(callOption *callOption)
+// CallOption configures a call before it starts and/or extracts information from it after
+// the response is received, like grpc.CallOption. Packages can add their own options by
+// implementing it, embedding EmptyCallOption, interceptors find them in the options of the call.
+type CallOption interface {
+	// Before is called before the call is sent, it sets the CallInfo of the call
+	Before(*CallInfo) error
+	// After is called once the call is finished, CallInfo.Response is the response if received
+	After(*CallInfo)
+}
 
-func WithHeader(header map[string]string) CallOption {
-	return func(callOption *callOption) {
-		callOption.Header = header
-	}
+// EmptyCallOption does not alter the call, it can be embedded by custom CallOption implementations
+type EmptyCallOption struct{}
+
+func (EmptyCallOption) Before(*CallInfo) error { return nil }
+func (EmptyCallOption) After(*CallInfo)        {}
+
+// beforeCallOption a CallOption setting the CallInfo
+type beforeCallOption func(info *CallInfo)
+
+func (o beforeCallOption) Before(info *CallInfo) error {
+	o(info)
+	return nil
 }
 
-func WithCallTimeOut(value int) CallOption {
-	return func(callOption *callOption) {
-		callOption.TimeOut = time.Second * time.Duration(value)
+func (o beforeCallOption) After(*CallInfo) {}
+
+// afterCallOption a CallOption reading the response, it is not called if the call failed before receiving it
+type afterCallOption func(response *http.Response)
+
+func (o afterCallOption) Before(*CallInfo) error { return nil }
+
+func (o afterCallOption) After(info *CallInfo) {
+	if info.Response != nil && info.Response.StatusCode != 0 {
+		o(info.Response)
 	}
 }
 
-func WithUrlParams(value map[string]string) CallOption {
-	return func(callOption *callOption) {
-		callOption.UrlParams = value
-	}
+// CallInfo the settings of a call, resolved once from its options on top of the ClientConn defaults.
+// A CallInfo is also a CallOption replacing the settings of the options before it by its own,
+// CallInterface implementations receive the CallInfo of the call as their only option.
+type CallInfo struct {
+	// DefaultHeader the headers of the ClientConn, the outgoing metadata and Header replace the same keys
+	DefaultHeader http.Header
+	Header        http.Header
+	TimeOut       time.Duration
+	UrlParams     map[string]string // url params,if raw url is /users/{uid},url params=map{"uid":123},then latest url is /users/123.
+	Body          io.Reader
+	// multipart/form-data, see WithMultipart
+	Multipart  bool
+	FileFields []string
+	KeyStyle   query.KeyStyle
+	// Options the options the CallInfo is resolved from, including the ones unknown to this package
+	Options []CallOption
+	// Response the response of the call, set before the After hooks run
+	Response *http.Response
 }
 
-type callOption struct {
-	Header    map[string]string
-	TimeOut   time.Duration
-	UrlParams map[string]string // url params,if raw url is /users/{uid},url params=map{"uid":123},then latest url is /users/123.
+func (info *CallInfo) Before(c *CallInfo) error {
+	*c = *info
+	// the options after it append to the copies, info may be reused by concurrent calls
+	c.Options = append([]CallOption(nil), info.Options...)
+	c.FileFields = append([]string(nil), info.FileFields...)
+	c.DefaultHeader = mergeHeader(nil, info.DefaultHeader)
+	c.Header = mergeHeader(nil, info.Header)
+	return nil
 }
 
-type CallOptions []CallOption
+func (info *CallInfo) After(*CallInfo) {}
 
-func (opts CallOptions) CombineHeader(header map[string]string) []CallOption {
-	callOpt := &callOption{}
+// apply run the Before hooks of opts on info
+func (info *CallInfo) apply(opts []CallOption) error {
 	for _, opt := range opts {
-		opt(callOpt)
+		if opt == nil {
+			continue
+		}
+		if err := opt.Before(info); err != nil {
+			return err
+		}
+		if _, ok := opt.(*CallInfo); !ok {
+			info.Options = append(info.Options, opt)
+		}
 	}
-	if callOpt.Header == nil {
-		callOpt.Header = map[string]string{}
+	return nil
+}
+
+// after set the response and run the After hooks of the options
+func (info *CallInfo) after(response *http.Response) {
+	info.Response = response
+	for _, opt := range info.Options {
+		opt.After(info)
 	}
+}
+
+// WithHeader set the request headers of the call, see WithHTTPHeader
+func WithHeader(header map[string]string) CallOption {
+	h := make(http.Header, len(header))
 	for k, v := range header {
-		if _, ok := callOpt.Header[k]; !ok {
-			callOpt.Header[k] = v
-		}
+		h.Set(k, v)
 	}
-	var options []CallOption
-	options = append(options, WithHeader(callOpt.Header))
-	if callOpt.TimeOut != 0 {
-		options = append(options, WithCallTimeOut(int(callOpt.TimeOut/time.Second)))
+	return WithHTTPHeader(h)
+}
+
+// WithHTTPHeader set the request headers of the call, all the values of a key are sent.
+// The keys replace the same keys of the previous options, of the http rule annotation and of the
+// default headers of the ClientConn: conn default < annotation < call. header is not modified.
+func WithHTTPHeader(header http.Header) CallOption {
+	return beforeCallOption(func(info *CallInfo) {
+		info.Header = mergeHeader(info.Header, header)
+	})
+}
+
+// mergeHeader return a copy of dst whose keys are replaced by the keys of src, dst and src are not modified
+func mergeHeader(dst, src http.Header) http.Header {
+	merged := make(http.Header, len(dst)+len(src))
+	for k, vs := range dst {
+		merged[k] = append([]string(nil), vs...)
 	}
-	if len(callOpt.UrlParams) > 0 {
-		options = append(options, WithUrlParams(callOpt.UrlParams))
+	for k, vs := range src {
+		merged[http.CanonicalHeaderKey(k)] = append([]string(nil), vs...)
 	}
-	return options
+	return merged
 }
 
-func (opts CallOptions) GetTimeOut() time.Duration {
-	callOpt := &callOption{}
-	for _, opt := range opts {
-		opt(callOpt)
+// WithCallTimeOut set the timeout in seconds
+//
+// Deprecated: use WithCallTimeout, which supports sub-second timeouts.
+func WithCallTimeOut(value int) CallOption {
+	return WithCallTimeout(time.Second * time.Duration(value))
+}
+
+// WithCallTimeout set the timeout of the call, the call deadline is the earlier of
+// the context deadline and now+timeout
+func WithCallTimeout(timeout time.Duration) CallOption {
+	return beforeCallOption(func(info *CallInfo) {
+		info.TimeOut = timeout
+	})
+}
+
+func WithUrlParams(value map[string]string) CallOption {
+	return beforeCallOption(func(info *CallInfo) {
+		info.UrlParams = value
+	})
+}
+
+// WithRequestBody send body as the request body instead of the encoded req, it is streamed without buffering
+func WithRequestBody(body io.Reader) CallOption {
+	return beforeCallOption(func(info *CallInfo) {
+		info.Body = body
+	})
+}
+
+// WithKeyStyle name the keys of slices and nested structs in query strings and form bodies with style
+func WithKeyStyle(style query.KeyStyle) CallOption {
+	return beforeCallOption(func(info *CallInfo) {
+		info.KeyStyle = style
+	})
+}
+
+// Header get the response header of the call
+func Header(header *http.Header) CallOption {
+	return afterCallOption(func(response *http.Response) {
+		*header = response.Header
+	})
+}
+
+// Trailer get the response trailer of the call
+func Trailer(trailer *http.Header) CallOption {
+	return afterCallOption(func(response *http.Response) {
+		*trailer = response.Trailer
+	})
+}
+
+// StatusCode get the response status code of the call, it is also set when the call returns an error
+// after the response is received, e.g. when the body can not be decoded
+func StatusCode(code *int) CallOption {
+	return afterCallOption(func(response *http.Response) {
+		*code = response.StatusCode
+	})
+}
+
+// RawResponse get the response of the call, its body is already read and closed except for streaming calls
+func RawResponse(raw **http.Response) CallOption {
+	return afterCallOption(func(response *http.Response) {
+		*raw = response
+	})
+}
+
+type CallOptions []CallOption
+
+// CombineHeader add the headers of the http rule annotation, the headers of the call options replace the same keys
+func (opts CallOptions) CombineHeader(header map[string]string) []CallOption {
+	return append([]CallOption{WithHeader(header)}, opts...)
+}
+
+// CallInfo resolve the options, a single CallInfo option is returned as is
+func (opts CallOptions) CallInfo() (*CallInfo, error) {
+	if len(opts) == 1 {
+		if info, ok := opts[0].(*CallInfo); ok {
+			return info, nil
+		}
 	}
-	return callOpt.TimeOut
+	info := &CallInfo{}
+	err := info.apply(opts)
+	return info, err
 }
 
+// callInfo resolve the options ignoring the errors of the Before hooks, they are returned when the call is resolved
+func (opts CallOptions) callInfo() *CallInfo {
+	info, _ := opts.CallInfo()
+	return info
+}
+
+func (opts CallOptions) GetTimeOut() time.Duration {
+	return opts.callInfo().TimeOut
+}
+
+// GetHeader return the first value of the request headers, keyed by canonical key
 func (opts CallOptions) GetHeader() map[string]string {
-	callOpt := &callOption{}
-	for _, opt := range opts {
-		opt(callOpt)
-	}
-	if callOpt.Header == nil {
-		callOpt.Header = map[string]string{}
+	header := make(map[string]string)
+	for k, vs := range opts.callInfo().Header {
+		if len(vs) > 0 {
+			header[k] = vs[0]
+		}
 	}
-	return callOpt.Header
+	return header
+}
+
+// GetHTTPHeader return a copy of the request headers, keyed by canonical key,
+// the headers of the options replace the default headers of the ClientConn
+func (opts CallOptions) GetHTTPHeader() http.Header {
+	info := opts.callInfo()
+	return mergeHeader(mergeHeader(nil, info.DefaultHeader), info.Header)
+}
+
+func (opts CallOptions) GetBody() io.Reader {
+	return opts.callInfo().Body
+}
+
+// GetMultipart return the file fields given by WithMultipart, ok is false if the request is not multipart
+func (opts CallOptions) GetMultipart() (fileFields []string, ok bool) {
+	info := opts.callInfo()
+	return info.FileFields, info.Multipart
+}
+
+func (opts CallOptions) GetKeyStyle() query.KeyStyle {
+	return opts.callInfo().KeyStyle
 }
 
 func (opts CallOptions) GetUrlParam() map[string]string {
-	callOpt := &callOption{}
-	for _, opt := range opts {
-		opt(callOpt)
-	}
-	return callOpt.UrlParams
+	return opts.callInfo().UrlParams
 }
 
 func getVariableUrlParams(url string) []string {
@@ -106,77 +282,110 @@ func getVariableUrlParams(url string) []string {
 }
 
 func (cc *ClientConn) Invoke(ctx context.Context, method string, api string, req interface{}, reply interface{}, opts ...CallOption) error {
-	addr := ""
-	var err error
-	if cc.direct {
-		addr = cc.target
-	} else {
-		addr, err = cc.GetPickerWrapper().Pick(ctx, false)
-		if err != nil {
-			return err
-		}
+	if cc.isClosed() {
+		return ErrClientConnClosing
 	}
-	if cc.connOption.secure {
-		addr = "https://" + addr
-	} else {
-		addr = "http://" + addr
+	info, err := newCallInfo(cc, opts)
+	if err != nil {
+		return err
+	}
+	// the deadline of the call covers the pick, a call waiting for addresses fails once it is exceeded
+	ctx, cancel := callContext(ctx, info.TimeOut)
+	defer cancel()
+	// the pick is done before the interceptors run, it is recorded for them, see wrapper.ContextPickRecord
+	record := wrapper.NewPickRecord()
+	addr, err := cc.pickBaseUrl(wrapper.WithPickTrace(ctx, record.Trace()))
+	if err != nil {
+		return err
 	}
 	request := &http.Request{Method: method, Host: addr, URL: &url.URL{Path: api}}
 
 	if cc.GetOption().unaryInterceptor != nil {
-		return cc.GetOption().unaryInterceptor(ctx, req, reply, request, &http.Response{}, cc, invoke, opts...)
+		return cc.GetOption().unaryInterceptor(wrapper.WithPickRecord(ctx, record), req, reply, request, &http.Response{}, cc, invoke, opts...)
 	}
 	return invoke(ctx, req, reply, request, &http.Response{}, cc, opts...)
 }
 
+// pickBaseUrl pick an address for the call and return its base url
+func (cc *ClientConn) pickBaseUrl(ctx context.Context) (string, error) {
+	if cc.direct {
+		return baseUrl(cc.target, cc.connOption.secure), nil
+	}
+	address, err := cc.GetPickerWrapper().PickAddress(ctx, false)
+	if err != nil {
+		return "", err
+	}
+	if cc.connOption.secure {
+		cc.transport.setServerName(address.Addr, address.ServerName)
+	}
+	return baseUrl(address.Addr, cc.connOption.secure), nil
+}
+
 func invoke(ctx context.Context, req interface{}, reply interface{}, httpRequest *http.Request, httpResponse *http.Response, cc *ClientConn, opts ...CallOption) error {
 	call := cc.GetOption().httpCall
 	addr := httpRequest.Host
 	api := httpRequest.URL.Path
+	if len(httpRequest.URL.Scheme) > 0 {
+		// httpRequest was sent by a previous invocation, e.g. an interceptor retrying the call
+		addr = httpRequest.URL.Scheme + "://" + httpRequest.URL.Host
+	}
 	method := strings.ToUpper(httpRequest.Method)
-	opts = combineCallOptions(cc, opts...)
-
-	urlParam := CallOptions(opts).GetUrlParam()
-	var err error
-	api, err = convertApi(api, urlParam)
+	info, err := newCallInfo(cc, opts)
 	if err != nil {
 		return err
 	}
+	api, err = convertApi(api, info.UrlParams)
+	if err != nil {
+		return err
+	}
+	// the deadline of the call is set by Invoke, before the pick and the interceptors
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	var request *http.Request
+	var response *http.Response
 	switch method {
 	case http.MethodGet:
-		httpRequest, httpResponse, err = call.Get(ctx, addr, api, req, reply, opts...)
+		request, response, err = call.Get(ctx, addr, api, req, reply, info)
 	case http.MethodPost:
-		httpRequest, httpResponse, err = call.Post(ctx, addr, api, req, reply, opts...)
+		request, response, err = call.Post(ctx, addr, api, req, reply, info)
 	case http.MethodPut:
-		httpRequest, httpResponse, err = call.Put(ctx, addr, api, req, reply, opts...)
+		request, response, err = call.Put(ctx, addr, api, req, reply, info)
 	case http.MethodDelete:
-		httpRequest, httpResponse, err = call.Delete(ctx, addr, api, req, reply, opts...)
+		request, response, err = call.Delete(ctx, addr, api, req, reply, info)
 	default:
-		httpRequest, httpResponse, err = call.Default(ctx, addr, api, req, reply, opts...)
+		request, response, err = call.Default(ctx, addr, api, req, reply, info)
+	}
+	info.after(response)
+	// interceptors see the request sent and the response received once the invoker returns
+	if request != nil {
+		*httpRequest = *request
+	}
+	if response != nil {
+		*httpResponse = *response
 	}
 	return err
 }
 
-// combineCallOptions integrate the TimeOut value in clientConn and CallOption, if CallOption is not configured, take the value in clientConn
-func combineCallOptions(cc *ClientConn, option ...CallOption) []CallOption {
-	callOpt := &callOption{}
-	for _, opt := range option {
-		opt(callOpt)
-	}
-	if callOpt.TimeOut == 0 {
-		callOpt.TimeOut = cc.connOption.timeOut
+// callContext return ctx with the deadline of the call, the earlier of the ctx deadline and now+timeout
+func callContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
 	}
-	var options []CallOption
-	if callOpt.Header != nil {
-		options = append(options, WithHeader(callOpt.Header))
-	}
-	if callOpt.TimeOut != 0 {
-		options = append(options, WithCallTimeOut(int(callOpt.TimeOut/time.Second)))
+	return context.WithCancel(ctx)
+}
+
+// newCallInfo resolve the options of a call on top of the defaults of the ClientConn, the TimeOut of the
+// ClientConn is used if the options do not set one. The context deadline, if earlier, still wins over the TimeOut value.
+func newCallInfo(cc *ClientConn, opts []CallOption) (*CallInfo, error) {
+	info := &CallInfo{DefaultHeader: cc.connOption.header}
+	if err := info.apply(opts); err != nil {
+		return nil, err
 	}
-	if len(callOpt.UrlParams) > 0 {
-		options = append(options, WithUrlParams(callOpt.UrlParams))
+	if info.TimeOut == 0 {
+		info.TimeOut = cc.connOption.timeOut
 	}
-	return options
+	return info, nil
 }
 
 // convertApi Convert the variable parameter variable in the api address to a value
@@ -1,7 +1,16 @@
 package http
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/classtorch/prpc/metadata"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -93,3 +102,218 @@ func Test_ConvertApi(t *testing.T) {
 	}
 	t.Log("success")
 }
+
+func Test_ResponseOptions(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Header().Set("X-Attempt", strconv.Itoa(attempts))
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write([]byte(`{}`))
+		w.Header().Set("X-Checksum", "abc")
+	}))
+	defer server.Close()
+
+	// retry once on 503, the interceptor sees the request sent and the response received
+	var seen []string
+	retry := func(ctx context.Context, req interface{}, reply interface{}, httpRequest *http.Request, httpResponse *http.Response, cc *ClientConn, invoker Invoker, option ...CallOption) error {
+		err := invoker(ctx, req, reply, httpRequest, httpResponse, cc, option...)
+		seen = append(seen, httpRequest.URL.Path+":"+strconv.Itoa(httpResponse.StatusCode))
+		if httpResponse.StatusCode == http.StatusServiceUnavailable {
+			err = invoker(ctx, req, reply, httpRequest, httpResponse, cc, option...)
+			seen = append(seen, httpRequest.URL.Path+":"+strconv.Itoa(httpResponse.StatusCode))
+		}
+		return err
+	}
+	client, err := NewClientConn(context.Background(), server.Listener.Addr().String(), WithInterceptor(retry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header, trailer http.Header
+	var code int
+	var response *http.Response
+	err = client.Invoke(context.Background(), "GET", "/users/{uid}", nil, &struct{}{},
+		WithUrlParams(map[string]string{"uid": "1"}), Header(&header), Trailer(&trailer), StatusCode(&code), RawResponse(&response))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(seen, ",") != "/users/1:503,/users/1:200" {
+		t.Fatalf("unexpected requests seen by the interceptor:%v", seen)
+	}
+	if code != http.StatusOK || header.Get("X-Attempt") != "2" || trailer.Get("X-Checksum") != "abc" || response == nil || response.StatusCode != code {
+		t.Fatalf("unexpected response, code:%v, header:%v, trailer:%v", code, header, trailer)
+	}
+
+	code = 0
+	err = client.Invoke(context.Background(), "POST", "/broken", nil, &struct{}{}, StatusCode(&code))
+	if err == nil || code != http.StatusBadGateway {
+		t.Fatalf("expect status code:%v with an error,but get:%v, err:%v", http.StatusBadGateway, code, err)
+	}
+}
+
+func Test_HeaderPrecedence(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(r.Header)
+	}))
+	defer server.Close()
+	client, err := NewClientConn(context.Background(), server.Listener.Addr().String(),
+		WithUserAgent("prpc-test"), WithDefaultHeader(http.Header{"X-Conn": {"conn"}, "X-Metadata": {"conn"}, "X-Annotation": {"conn"}, "X-Call": {"conn"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	annotation := map[string]string{"x-annotation": "annotation", "X-Call": "annotation"}
+	callHeader := http.Header{"x-call": {"call"}, "Cookie": {"a=1", "b=2"}}
+	opts := CallOptions([]CallOption{WithHTTPHeader(callHeader), WithHeader(map[string]string{"Content-Type": ContentTypeForm + "; charset=utf-8"})}).CombineHeader(annotation)
+	// the outgoing metadata replaces the default headers of the ClientConn, the headers of the call options replace it
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-metadata", "md1", "x-metadata", "md2", "x-call", "md")
+	reply := http.Header{}
+	if err = client.Invoke(ctx, "POST", "/header", &struct{}{}, &reply, opts...); err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string][]string{
+		"User-Agent":   {"prpc-test"},
+		"X-Conn":       {"conn"},
+		"X-Metadata":   {"md1", "md2"},
+		"X-Annotation": {"annotation"},
+		"X-Call":       {"call"},
+		"Cookie":       {"a=1", "b=2"},
+		ContentType:    {ContentTypeForm + "; charset=utf-8"},
+	}
+	for k, v := range expect {
+		if strings.Join(reply[k], ",") != strings.Join(v, ",") {
+			t.Fatalf("expect %s:%v,but get:%v", k, v, reply[k])
+		}
+	}
+	// the caller-owned headers are not modified
+	if len(annotation) != 2 || annotation["X-Call"] != "annotation" || len(callHeader) != 2 || callHeader["x-call"][0] != "call" {
+		t.Fatalf("caller headers modified, annotation:%v, call:%v", annotation, callHeader)
+	}
+	if !checkPostFrom(opts...) {
+		t.Fatal("expect a form request")
+	}
+}
+
+// tenantOption a third-party option setting a header before the call and recording the status after it
+type tenantOption struct {
+	EmptyCallOption
+	tenant string
+	status *int
+}
+
+func (o *tenantOption) Before(info *CallInfo) error {
+	if o.tenant == "" {
+		return errors.New("tenant empty")
+	}
+	info.Header = mergeHeader(info.Header, http.Header{"X-Tenant": {o.tenant}})
+	return nil
+}
+
+func (o *tenantOption) After(info *CallInfo) {
+	if info.Response != nil {
+		*o.status = info.Response.StatusCode
+	}
+}
+
+func Test_CustomCallOption(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(r.Header)
+	}))
+	defer server.Close()
+	var intercepted *tenantOption
+	interceptor := func(ctx context.Context, req interface{}, reply interface{}, httpRequest *http.Request, httpResponse *http.Response, cc *ClientConn, invoker Invoker, option ...CallOption) error {
+		for _, opt := range option {
+			if o, ok := opt.(*tenantOption); ok {
+				intercepted = o
+			}
+		}
+		return invoker(ctx, req, reply, httpRequest, httpResponse, cc, option...)
+	}
+	client, err := NewClientConn(context.Background(), server.Listener.Addr().String(), WithInterceptor(interceptor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status int
+	tenant := &tenantOption{tenant: "acme", status: &status}
+	opts := CallOptions([]CallOption{tenant}).CombineHeader(map[string]string{"X-Annotation": "annotation"})
+	reply := http.Header{}
+	if err = client.Invoke(context.Background(), "GET", "/tenant", nil, &reply, opts...); err != nil {
+		t.Fatal(err)
+	}
+	if intercepted != tenant {
+		t.Fatal("expect the interceptor to see the custom option")
+	}
+	if reply.Get("X-Tenant") != "acme" || reply.Get("X-Annotation") != "annotation" || status != http.StatusOK {
+		t.Fatalf("unexpected call, header:%v, status:%v", reply, status)
+	}
+
+	// the errors of the Before hooks fail the call before it is sent
+	status = 0
+	err = client.Invoke(context.Background(), "GET", "/tenant", nil, &reply, &tenantOption{status: &status})
+	if err == nil || status != 0 {
+		t.Fatalf("expect err:tenant empty,but get:%v, status:%v", err, status)
+	}
+
+	// a resolved CallInfo is an option too
+	info, err := CallOptions(opts).CallInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Options) != 2 || info.Header.Get("X-Tenant") != "acme" {
+		t.Fatalf("unexpected call info:%+v", info)
+	}
+	if resolved, _ := CallOptions([]CallOption{info}).CallInfo(); resolved != info {
+		t.Fatal("expect a single CallInfo option to be returned as is")
+	}
+}
+
+func Test_CallInfoReuse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Index", r.Header.Get("X-Index"))
+		json.NewEncoder(w).Encode(r.Header)
+	}))
+	defer server.Close()
+	client, err := NewClientConn(context.Background(), server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := CallOptions([]CallOption{WithHTTPHeader(http.Header{"X-Tenant": {"acme"}}), WithHeader(map[string]string{"X-Annotation": "annotation"})}).CallInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// some spare capacity so the appends of the calls would share the array of info.Options
+	info.Options = append(make([]CallOption, 0, 8), info.Options...)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(index string) {
+			defer wg.Done()
+			var h http.Header
+			reply := http.Header{}
+			err := client.Invoke(context.Background(), "GET", "/reuse", nil, &reply, info, WithHTTPHeader(http.Header{"X-Index": {index}}), Header(&h))
+			if err != nil {
+				errs <- err
+				return
+			}
+			if h.Get("X-Index") != index || reply.Get("X-Tenant") != "acme" {
+				errs <- fmt.Errorf("expect:X-Index %s,but get response header:%v, request header:%v", index, h, reply)
+			}
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if len(info.Options) != 2 || len(info.Header) != 2 || info.Header.Get("X-Index") != "" {
+		t.Fatalf("expect:the reused call info not modified,but get:%+v", info)
+	}
+}
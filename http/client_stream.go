@@ -0,0 +1,85 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"github.com/classtorch/prpc/metadata"
+	"mime"
+	"net/http"
+)
+
+const (
+	ContentTypeEventStream = "text/event-stream"
+	ContentTypeNDJSON      = "application/x-ndjson"
+	Accept                 = "Accept"
+)
+
+var (
+	ErrSendNotSupported = errors.New("http stream does not support sending messages")
+)
+
+// ClientStream a server-streaming call over http. It implements grpc.ClientStream so that
+// the generated clients return the same stream types for http and grpc.
+// Messages are decoded from a text/event-stream response, or else from a newline delimited JSON one.
+type ClientStream struct {
+	ctx     context.Context
+	header  metadata.MD
+	trailer http.Header
+	decode  func(v interface{}) error
+	close   func() error
+}
+
+// NewStream send the request of a server-streaming call, the stream is ended by reading until io.EOF
+// or by canceling ctx. See InvokeStream for the timeouts applied, like InvokeStream the interceptors
+// of the ClientConn, and so the metrics and the tracing they implement, are not applied to the stream.
+func (cc *ClientConn) NewStream(ctx context.Context, method string, api string, req interface{}, opts ...CallOption) (*ClientStream, error) {
+	opts = CallOptions(opts).CombineHeader(map[string]string{Accept: ContentTypeEventStream + ", " + ContentTypeNDJSON})
+	ctx, response, err := cc.invokeStream(ctx, method, api, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	stream := &ClientStream{ctx: ctx, header: metadata.FromHTTPHeader(response.Header), trailer: response.Trailer}
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get(ContentType))
+	if mediaType == ContentTypeEventStream {
+		decoder := NewSSEDecoder(response.Body)
+		stream.decode, stream.close = decoder.Decode, decoder.Close
+	} else {
+		decoder := NewNDJSONDecoder(response.Body)
+		stream.decode, stream.close = decoder.Decode, decoder.Close
+	}
+	return stream, nil
+}
+
+// Header return the response headers
+func (s *ClientStream) Header() (metadata.MD, error) {
+	return s.header, nil
+}
+
+// Trailer return the response trailer, it is complete once RecvMsg returned an error
+func (s *ClientStream) Trailer() metadata.MD {
+	return metadata.FromHTTPHeader(s.trailer)
+}
+
+// CloseSend the request is already sent
+func (s *ClientStream) CloseSend() error {
+	return nil
+}
+
+// Context return the call context
+func (s *ClientStream) Context() context.Context {
+	return s.ctx
+}
+
+// SendMsg http streams are server-streaming only
+func (s *ClientStream) SendMsg(m interface{}) error {
+	return ErrSendNotSupported
+}
+
+// RecvMsg decode the next message into m, it returns io.EOF and releases the call at the end of the stream
+func (s *ClientStream) RecvMsg(m interface{}) error {
+	err := s.decode(m)
+	if err != nil {
+		s.close()
+	}
+	return err
+}
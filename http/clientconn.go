@@ -2,12 +2,17 @@ package http
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"github.com/classtorch/prpc/balancer"
 	"github.com/classtorch/prpc/logger"
+	"github.com/classtorch/prpc/metrics"
 	"github.com/classtorch/prpc/resolver"
 	"github.com/classtorch/prpc/wrapper"
-	"log"
-	"os"
+	"net"
+	"net/http"
+	"net/url"
 	"sync"
 	"time"
 )
@@ -17,6 +22,10 @@ const (
 	defaultSecure  = false
 )
 
+var (
+	ErrClientConnClosing = errors.New("http ClientConn is closing")
+)
+
 // ClientConn http ClientConn
 type ClientConn struct {
 	ctx             context.Context
@@ -28,34 +37,61 @@ type ClientConn struct {
 	resolverWrapper *wrapper.CCResolverWrapper
 	balancerWrapper *wrapper.CCBalancerWrapper
 	pickerWrapper   *wrapper.PickerWrapper
+	transport       *clientTransport
+	poolStats       *poolStats
+	closed          bool
 }
 
 // connectOption http ClientConn connect Option
 type connectOption struct {
 	timeOut          time.Duration
 	resolverBuilder  []resolver.Builder
+	resolverRegistry *resolver.Registry
 	balancerBuilder  balancer.Builder
+	balancerRegistry *balancer.Registry
 	chanInterceptor  []Interceptor
 	unaryInterceptor Interceptor
 	curBalancerName  string
 	httpCall         CallInterface
 	secure           bool
-	log              logger.Log
+	log              logger.Logger
+	// transport
+	transport           *http.Transport
+	maxIdleConnsPerHost int
+	tlsConfig           *tls.Config
+	proxy               func(*http.Request) (*url.URL, error)
+	dialer              *net.Dialer
+	rootCAs             *x509.CertPool
+	rootCAFile          string
+	clientCertFile      string
+	clientKeyFile       string
+	protocol            Protocol
+	// default headers of the calls
+	header  http.Header
+	metrics metrics.MetricsRecorder
 }
 
 func defaultConnectOption() connectOption {
 	return connectOption{
 		timeOut: defaultTimeOut,
 		secure:  defaultSecure,
-		log:     logger.NewDefaultLogger(log.New(os.Stderr, logger.DefaultLogPrefix, log.LstdFlags)),
+		log:     logger.Default(),
 	}
 }
 
 type ConnOption func(*connectOption)
 
+// WithTimeOut set the timeout in seconds
+//
+// Deprecated: use WithTimeout, which supports sub-second timeouts.
 func WithTimeOut(value int) ConnOption {
+	return WithTimeout(time.Second * time.Duration(value))
+}
+
+// WithTimeout set the default timeout of the calls
+func WithTimeout(timeout time.Duration) ConnOption {
 	return func(o *connectOption) {
-		o.timeOut = time.Second * time.Duration(value)
+		o.timeOut = timeout
 	}
 }
 
@@ -89,12 +125,117 @@ func WithInterceptor(interceptors ...Interceptor) ConnOption {
 	}
 }
 
+// WithResolverRegistry look up resolver Builders not given by WithResolver in registry instead of the default one
+func WithResolverRegistry(registry *resolver.Registry) ConnOption {
+	return func(o *connectOption) {
+		o.resolverRegistry = registry
+	}
+}
+
+// WithBalancerRegistry look up the balancer Builder in registry instead of the default one
+func WithBalancerRegistry(registry *balancer.Registry) ConnOption {
+	return func(o *connectOption) {
+		o.balancerRegistry = registry
+	}
+}
+
+// WithMetricsRecorder record the picks and the ready addresses of the ClientConn to recorder
+func WithMetricsRecorder(recorder metrics.MetricsRecorder) ConnOption {
+	return func(o *connectOption) {
+		o.metrics = recorder
+	}
+}
+
+// WithLog log with log, see WithLogger for a structured logger
 func WithLog(log logger.Log) ConnOption {
+	return WithLogger(logger.FromLog(log))
+}
+
+// WithLogger log with log instead of logger.Default, the logs are named http and have the target as field
+func WithLogger(log logger.Logger) ConnOption {
 	return func(o *connectOption) {
 		o.log = log
 	}
 }
 
+// WithTransport use a copy of transport for all calls of the ClientConn
+func WithTransport(transport *http.Transport) ConnOption {
+	return func(o *connectOption) {
+		o.transport = transport
+	}
+}
+
+// WithMaxIdleConnsPerHost set the max idle connections kept per host
+func WithMaxIdleConnsPerHost(value int) ConnOption {
+	return func(o *connectOption) {
+		o.maxIdleConnsPerHost = value
+	}
+}
+
+// WithTLSConfig set the TLS config of https calls
+func WithTLSConfig(config *tls.Config) ConnOption {
+	return func(o *connectOption) {
+		o.tlsConfig = config
+	}
+}
+
+// WithRootCAs verify the server certificates with pool instead of the system roots
+func WithRootCAs(pool *x509.CertPool) ConnOption {
+	return func(o *connectOption) {
+		o.rootCAs = pool
+	}
+}
+
+// WithRootCAFile verify the server certificates with the PEM certificates of file instead of the system roots
+func WithRootCAFile(file string) ConnOption {
+	return func(o *connectOption) {
+		o.rootCAFile = file
+	}
+}
+
+// WithClientCertificate authenticate to servers with the PEM certificate and key files,
+// they are reloaded on change without recreating the ClientConn
+func WithClientCertificate(certFile, keyFile string) ConnOption {
+	return func(o *connectOption) {
+		o.clientCertFile = certFile
+		o.clientKeyFile = keyFile
+	}
+}
+
+// WithProtocol select the http protocol spoken to the servers, ProtocolAuto by default
+func WithProtocol(protocol Protocol) ConnOption {
+	return func(o *connectOption) {
+		o.protocol = protocol
+	}
+}
+
+// WithDefaultHeader set headers sent by all the calls, the outgoing metadata and the headers of the
+// http rule annotations and of the call options replace the same keys
+func WithDefaultHeader(header http.Header) ConnOption {
+	return func(o *connectOption) {
+		o.header = mergeHeader(o.header, header)
+	}
+}
+
+// WithUserAgent set the User-Agent header of the calls
+func WithUserAgent(userAgent string) ConnOption {
+	return WithDefaultHeader(http.Header{"User-Agent": {userAgent}})
+}
+
+// WithProxy set the proxy of the calls, see http.Transport.Proxy
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) ConnOption {
+	return func(o *connectOption) {
+		o.proxy = proxy
+	}
+}
+
+// WithDialer dial the connections with dialer
+func WithDialer(dialer *net.Dialer) ConnOption {
+	return func(o *connectOption) {
+		o.dialer = dialer
+	}
+}
+
 // NewClientConn init a http ClientConn
 func NewClientConn(ctx context.Context, target string, opts ...ConnOption) (*ClientConn, error) {
 	cc := &ClientConn{
@@ -104,24 +245,37 @@ func NewClientConn(ctx context.Context, target string, opts ...ConnOption) (*Cli
 	for _, option := range opts {
 		option(&cc.connOption)
 	}
+	cc.connOption.log = cc.connOption.log.Named("http").With("target", target)
 
+	cc.poolStats = newPoolStats()
+	transport, err := newTransport(cc.connOption, cc.poolStats)
+	if err != nil {
+		return nil, err
+	}
+	cc.transport = transport
 	if cc.connOption.httpCall == nil {
-		cc.connOption.httpCall = NewDefaultPRpcHttpClient()
+		cc.connOption.httpCall = NewPRpcHttpClient(&countedRoundTripper{base: cc.transport, stats: cc.poolStats})
 	}
 
 	ChainUnaryInterceptors(cc)
 
-	parseTarget := resolver.ParseTarget(target)
+	parseTarget, err := resolver.ParseTarget(target)
+	if err != nil {
+		return nil, err
+	}
 	scheme := parseTarget.Scheme
-	if scheme == resolver.GetPassThroughScheme() {
+	if scheme == resolver.GetPassThroughScheme() || isUnixTarget(scheme) {
 		cc.direct = true
 		return cc, nil
 	}
 	cc.parseTarget = parseTarget
 
-	pickerWrapper := wrapper.NewPickerWrapper(cc.connOption.log)
+	pickerWrapper := wrapper.NewPickerWrapperWithLogger(cc.connOption.log.Named("balancer"))
+	if cc.connOption.metrics != nil {
+		pickerWrapper.SetMetricsRecorder(parseTarget.Endpoint, cc.connOption.metrics)
+	}
 	cc.pickerWrapper = pickerWrapper
-	balancerWrapper, err := wrapper.GetBalancerWrapper(cc.connOption.curBalancerName, pickerWrapper)
+	balancerWrapper, err := wrapper.GetBalancerWrapper(cc.connOption.balancerRegistry, cc.connOption.curBalancerName, pickerWrapper)
 	if err != nil {
 		return nil, err
 	}
@@ -139,6 +293,12 @@ func NewClientConn(ctx context.Context, target string, opts ...ConnOption) (*Cli
 	return cc, nil
 }
 
+// ResolverBuilder return the resolver Builder of scheme, the meta-resolvers such as failover
+// build their children with the resolvers of the ClientConn
+func (cc *ClientConn) ResolverBuilder(scheme string) resolver.Builder {
+	return cc.getResolverBuilder(scheme)
+}
+
 // getResolverBuilder return resolver Builder
 func (cc *ClientConn) getResolverBuilder(scheme string) resolver.Builder {
 	for _, rb := range cc.connOption.resolverBuilder {
@@ -146,13 +306,22 @@ func (cc *ClientConn) getResolverBuilder(scheme string) resolver.Builder {
 			return rb
 		}
 	}
+	if cc.connOption.resolverRegistry != nil {
+		return cc.connOption.resolverRegistry.Get(scheme)
+	}
 	return resolver.Get(scheme)
 }
 
 // UpdateResolverState update balancer State
+// when the resolver reports an error the last known good addresses are kept
 func (cc *ClientConn) UpdateResolverState(state resolver.State, err error) error {
 	cc.mu.Lock()
 	defer cc.mu.Unlock()
+	if err != nil {
+		cc.connOption.log.Error("resolver error, keep the last addresses", "err", err)
+		cc.balancerWrapper.ResolverError(err)
+		return nil
+	}
 	cc.balancerWrapper.UpdateState(state)
 	return nil
 }
@@ -171,3 +340,33 @@ func (cc *ClientConn) GetOption() connectOption {
 func (cc *ClientConn) GetPickerWrapper() *wrapper.PickerWrapper {
 	return cc.pickerWrapper
 }
+
+// PoolStats return the connection pool statistics of the ClientConn's transport, keyed by "host:port".
+// They only cover calls made by the default http client impl.
+func (cc *ClientConn) PoolStats() map[string]HostPoolStats {
+	return cc.poolStats.snapshot()
+}
+
+// Close close the resolver and the idle connections of the ClientConn,
+// subsequent calls return ErrClientConnClosing
+func (cc *ClientConn) Close() error {
+	cc.mu.Lock()
+	if cc.closed {
+		cc.mu.Unlock()
+		return ErrClientConnClosing
+	}
+	cc.closed = true
+	cc.mu.Unlock()
+	if cc.resolverWrapper != nil {
+		cc.resolverWrapper.Close()
+	}
+	cc.transport.CloseIdleConnections()
+	return nil
+}
+
+// isClosed whether Close was called
+func (cc *ClientConn) isClosed() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.closed
+}
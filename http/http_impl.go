@@ -5,13 +5,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
+	"github.com/classtorch/prpc/metadata"
 	"github.com/classtorch/prpc/pkg/query"
+	"google.golang.org/protobuf/proto"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
-	"reflect"
 	"strings"
 	"time"
 )
@@ -22,6 +23,11 @@ const (
 	ContentTypeJson = "application/json;charset=utf-8"
 )
 
+var (
+	// defaultTransport transport of the default http client impl, it dials unix socket addresses too
+	defaultTransport = withUnixDialer(http.DefaultTransport.(*http.Transport).Clone())
+)
+
 // CallInterface http client impl interface
 type CallInterface interface {
 	Get(ctx context.Context, addr string, api string, req interface{}, reply interface{}, opts ...CallOption) (*http.Request, *http.Response, error)
@@ -33,27 +39,25 @@ type CallInterface interface {
 
 // defaultHttpClient default http client impl
 type defaultHttpClient struct {
+	transport http.RoundTripper
 }
 
+// NewDefaultPRpcHttpClient return the default http client impl sending requests through a process wide transport
 func NewDefaultPRpcHttpClient() CallInterface {
-	return &defaultHttpClient{}
+	return NewPRpcHttpClient(defaultTransport)
+}
+
+// NewPRpcHttpClient return the default http client impl sending requests through transport
+func NewPRpcHttpClient(transport http.RoundTripper) CallInterface {
+	return &defaultHttpClient{transport: transport}
 }
 
 func (cc *defaultHttpClient) Get(ctx context.Context, addr string, api string, req interface{}, reply interface{}, opts ...CallOption) (*http.Request, *http.Response, error) {
-	values, err := query.Values(req, "json")
+	request, err := getQueryRequest(ctx, addr+api, req, opts...)
 	if err != nil {
 		return nil, nil, err
 	}
-	queryParams := values.Encode()
-	url := addr + api
-	if len(queryParams) > 0 {
-		url = addr + api + "?" + queryParams
-	}
-	request, err := getRequest(ctx, url, http.MethodGet, nil, opts...)
-	if err != nil {
-		return nil, nil, err
-	}
-	response, err := do(request, CallOptions(opts).GetTimeOut(), reply)
+	response, err := cc.do(request, CallOptions(opts).GetTimeOut(), reply)
 	return request, response, err
 }
 
@@ -74,27 +78,65 @@ func (cc *defaultHttpClient) Default(ctx context.Context, addr string, api strin
 }
 
 func (cc *defaultHttpClient) doBodyRequest(ctx context.Context, addr string, api string, method string, req interface{}, reply interface{}, opts ...CallOption) (*http.Request, *http.Response, error) {
-	var reader io.Reader
-	if checkPostFrom(opts...) {
-		params, err := getPostFormParams(req)
+	request, err := getBodyRequest(ctx, addr+api, method, req, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	response, err := cc.do(request, CallOptions(opts).GetTimeOut(), reply)
+	return request, response, err
+}
+
+// getQueryRequest return http GET Request with req encoded in the query string,
+// proto messages are encoded following the google.api.http mapping regardless of the key style
+func getQueryRequest(ctx context.Context, rawUrl string, req interface{}, opts ...CallOption) (*http.Request, error) {
+	var values url.Values
+	var err error
+	if message, ok := req.(proto.Message); ok {
+		values, err = query.ProtoValues(message)
+	} else {
+		values, err = query.ValuesWithStyle(req, "json", CallOptions(opts).GetKeyStyle())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if queryParams := values.Encode(); len(queryParams) > 0 {
+		rawUrl = rawUrl + "?" + queryParams
+	}
+	return getRequest(ctx, rawUrl, http.MethodGet, nil, opts...)
+}
+
+// getBodyRequest return http Request whose body is the WithRequestBody reader if given, else the encoded req
+func getBodyRequest(ctx context.Context, url, method string, req interface{}, opts ...CallOption) (*http.Request, error) {
+	reader := CallOptions(opts).GetBody()
+	if fileFields, ok := CallOptions(opts).GetMultipart(); ok && reader == nil {
+		body, contentType, err := getMultipartBody(req, fileFields, CallOptions(opts).GetKeyStyle())
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
-		reader = strings.NewReader(params.Encode())
-	} else {
-		bys, err := json.Marshal(req)
+		request, err := getRequest(ctx, url, method, body, opts...)
 		if err != nil {
-			return nil, nil, err
+			body.Close()
+			return nil, err
+		}
+		request.Header.Set(ContentType, contentType)
+		return request, nil
+	}
+	if reader == nil {
+		if checkPostFrom(opts...) {
+			params, err := getPostFormParams(req, CallOptions(opts).GetKeyStyle())
+			if err != nil {
+				return nil, err
+			}
+			reader = strings.NewReader(params.Encode())
+		} else {
+			bys, err := json.Marshal(req)
+			if err != nil {
+				return nil, err
+			}
+			reader = bytes.NewReader(bys)
 		}
-		reader = bytes.NewReader(bys)
-	}
-	url := addr + api
-	request, err := getRequest(ctx, url, method, reader, opts...)
-	if err != nil {
-		return nil, nil, err
 	}
-	response, err := do(request, CallOptions(opts).GetTimeOut(), reply)
-	return request, response, err
+	return getRequest(ctx, url, method, reader, opts...)
 }
 
 // getRequest return http Request
@@ -103,59 +145,55 @@ func getRequest(ctx context.Context, url, method string, reader io.Reader, opts
 	if err != nil {
 		return nil, err
 	}
-	header := CallOptions(opts).GetHeader()
-	if _, ok := header[ContentType]; !ok {
-		header[ContentType] = ContentTypeJson
+	if _, ok := unixSocket(request.URL.Host); ok {
+		request.Host = unixHostHeader
+	}
+	// the default headers of the ClientConn, then the outgoing metadata, then the headers of the call options,
+	// each replacing the keys set before
+	info := CallOptions(opts).callInfo()
+	for k, vs := range mergeHeader(nil, info.DefaultHeader) {
+		request.Header[k] = vs
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		header := make(http.Header, len(md))
+		metadata.ToHTTPHeader(md, header)
+		for k, vs := range header {
+			request.Header[k] = vs
+		}
+	}
+	for k, vs := range mergeHeader(nil, info.Header) {
+		request.Header[k] = vs
+	}
+	// the timeout header always carries the deadline of the call, whatever the headers above set
+	if deadline, ok := ctx.Deadline(); ok {
+		request.Header.Set(TimeoutHeader, EncodeTimeout(time.Until(deadline)))
+	} else {
+		request.Header.Del(TimeoutHeader)
 	}
-	for k, v := range header {
-		request.Header.Set(k, v)
+	if len(request.Header.Get(ContentType)) == 0 {
+		request.Header.Set(ContentType, ContentTypeJson)
 	}
 	return request, nil
 }
 
-// getPostFormParams convert req to form format params
-func getPostFormParams(req interface{}) (url.Values, error) {
-	params := make(url.Values)
-	value := reflect.ValueOf(req)
-	if value.Kind() != reflect.Struct {
-		value = value.Elem()
-	}
-	if value.Kind() != reflect.Struct {
-		return nil, errors.New("req not struct")
-	}
-	n := value.NumField()
-	valType := value.Type()
-	for i := 0; i < n; i++ {
-		field := valType.Field(i)
-		val := value.Field(i)
-
-		tagValue := field.Tag.Get("json")
-		if len(tagValue) > 0 {
-			tagValues := strings.Split(tagValue, ",")
-			if len(tagValues) >= 1 {
-				valStr := fmt.Sprintf("%v", val.Interface())
-				params[tagValues[0]] = []string{valStr}
-			}
-		}
-	}
-	return params, nil
+// getPostFormParams convert req to form format params, encoded like the query string of GET requests
+func getPostFormParams(req interface{}, style query.KeyStyle) (url.Values, error) {
+	return query.ValuesWithStyle(req, "json", style)
 }
 
 // checkPostFrom determine whether the request is a post form
 func checkPostFrom(opts ...CallOption) bool {
-	isForm := false
-	for k, v := range CallOptions(opts).GetHeader() {
-		if k == ContentType && v == ContentTypeForm {
-			isForm = true
-			break
-		}
-	}
-	return isForm
+	mediaType, _, _ := mime.ParseMediaType(CallOptions(opts).GetHTTPHeader().Get(ContentType))
+	return mediaType == ContentTypeForm
 }
 
-// do execute request
-func do(request *http.Request, timeOut time.Duration, reply interface{}) (*http.Response, error) {
-	client := http.Client{Timeout: timeOut}
+// do execute request, the response is returned with the errors occurring after it is received.
+// timeOut is only applied to a request without deadline, ClientConn.Invoke already set it as the context deadline
+func (cc *defaultHttpClient) do(request *http.Request, timeOut time.Duration, reply interface{}) (*http.Response, error) {
+	client := http.Client{Transport: cc.transport}
+	if _, ok := request.Context().Deadline(); !ok {
+		client.Timeout = timeOut
+	}
 	resp, err := client.Do(request)
 	if err != nil {
 		return nil, err
@@ -165,14 +203,14 @@ func do(request *http.Request, timeOut time.Duration, reply interface{}) (*http.
 	}
 	respBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return resp, err
 	}
 	if respBytes == nil || len(respBytes) == 0 {
-		return nil, errors.New("response empty")
+		return resp, errors.New("response empty")
 	}
 	err = json.Unmarshal(respBytes, reply)
 	if err != nil {
-		return nil, err
+		return resp, err
 	}
 	return resp, nil
 }
@@ -2,6 +2,14 @@ package http
 
 import (
 	"context"
+	"encoding/json"
+	"github.com/classtorch/prpc/metadata"
+	"github.com/classtorch/prpc/pkg/query"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"testing"
 )
 
@@ -21,3 +29,77 @@ func Test_Get(t *testing.T) {
 	}
 	t.Log("success")
 }
+
+type formItem struct {
+	Id int `json:"id"`
+}
+
+type formReq struct {
+	Name    string     `json:"name,omitempty"`
+	Empty   string     `json:"empty,omitempty"`
+	Tags    []string   `json:"tags"`
+	Items   []formItem `json:"items"`
+	Owner   *formItem  `json:"owner"`
+	Ignored string     `json:"-"`
+}
+
+func Test_PostForm(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		values, _ := url.ParseQuery(string(body))
+		json.NewEncoder(w).Encode(map[string]string{"body": values.Encode()})
+	}))
+	defer server.Close()
+	req := &formReq{Name: "prpc", Tags: []string{"a", "b"}, Items: []formItem{{Id: 1}, {Id: 2}}, Owner: &formItem{Id: 3}, Ignored: "x"}
+	testCases := []struct {
+		style  query.KeyStyle
+		expect string
+	}{
+		{style: query.KeyStyleDefault, expect: "items[0][id]=1&items[1][id]=2&name=prpc&owner[id]=3&tags=a&tags=b"},
+		{style: query.KeyStyleBrackets, expect: "items[0][id]=1&items[1][id]=2&name=prpc&owner[id]=3&tags[]=a&tags[]=b"},
+		{style: query.KeyStyleIndexed, expect: "items[0][id]=1&items[1][id]=2&name=prpc&owner[id]=3&tags[0]=a&tags[1]=b"},
+		{style: query.KeyStyleDotted, expect: "items[0].id=1&items[1].id=2&name=prpc&owner.id=3&tags=a&tags=b"},
+	}
+	client := NewDefaultPRpcHttpClient()
+	for _, testCase := range testCases {
+		reply := map[string]string{}
+		_, _, err := client.Post(context.Background(), server.URL, "/form", req, &reply,
+			WithHeader(map[string]string{ContentType: ContentTypeForm}), WithKeyStyle(testCase.style))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := url.QueryUnescape(reply["body"]); body != testCase.expect {
+			t.Fatalf("expect:%v,but get:%v", testCase.expect, body)
+		}
+	}
+}
+
+func Test_OutgoingMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string][]string{
+			"x-user":    r.Header.Values("X-User"),
+			"x-tag":     r.Header.Values("X-Tag"),
+			"x-bin":     r.Header.Values("X-Bin"),
+			"x-replace": r.Header.Values("X-Replace"),
+			"type":      r.Header.Values(ContentType),
+		})
+	}))
+	defer server.Close()
+	ctx := metadata.AppendToOutgoingContext(context.Background(),
+		"x-user", "1", "x-tag", "a", "x-tag", "b", "x-bin", "\xff", "x-replace", "md", "content-type", "text/plain")
+	reply := map[string][]string{}
+	_, _, err := NewDefaultPRpcHttpClient().Post(ctx, server.URL, "/metadata", nil, &reply, WithHeader(map[string]string{"X-Replace": "option"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string][]string{
+		"x-user":    {"1"},
+		"x-tag":     {"a", "b"},
+		"x-bin":     {"/w"},
+		"x-replace": {"option"},
+		"type":      {ContentTypeJson},
+	}
+	if !reflect.DeepEqual(reply, expect) {
+		t.Fatalf("expect:%v,but get:%v", expect, reply)
+	}
+}
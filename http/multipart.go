@@ -0,0 +1,232 @@
+package http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"github.com/classtorch/prpc/pkg/query"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+const (
+	ContentTypeMultipart   = "multipart/form-data"
+	ContentTypeOctetStream = "application/octet-stream"
+)
+
+var (
+	filePartType = reflect.TypeOf(FilePart{})
+	readerType   = reflect.TypeOf((*io.Reader)(nil)).Elem()
+)
+
+// FilePart a file sent as a part of a multipart/form-data body
+type FilePart struct {
+	Filename    string
+	ContentType string // ContentTypeOctetStream if empty
+	Body        io.Reader
+}
+
+// WithMultipart send the request as multipart/form-data. Fields of type FilePart, *FilePart or io.Reader
+// are sent as file parts, as are the []byte fields tagged `multipart:"file"` or whose json name is in fileFields,
+// the other fields are sent as form fields, the other []byte fields as a single base64 value like encoding/json does.
+// The fields of proto messages are sent following the google.api.http mapping, their bytes fields named in
+// fileFields as files. Files are streamed, not buffered.
+func WithMultipart(fileFields ...string) CallOption {
+	return beforeCallOption(func(info *CallInfo) {
+		info.Multipart = true
+		info.FileFields = append(info.FileFields, fileFields...)
+	})
+}
+
+// multipartFile a file field of the request
+type multipartFile struct {
+	name string
+	part FilePart
+}
+
+// getMultipartBody return the multipart/form-data body of req and its content type, the body is written
+// by a goroutine as the request is sent
+func getMultipartBody(req interface{}, fileFields []string, style query.KeyStyle) (io.ReadCloser, string, error) {
+	var files []multipartFile
+	var values url.Values
+	var err error
+	if message, ok := req.(proto.Message); ok {
+		files, values, err = getProtoMultipartFields(message, fileFields)
+	} else {
+		files, values, err = getMultipartFields(req, fileFields, style)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	reader, writer := io.Pipe()
+	mw := multipart.NewWriter(writer)
+	go func() {
+		writer.CloseWithError(writeMultipart(mw, values, files))
+	}()
+	return reader, mw.FormDataContentType(), nil
+}
+
+// getMultipartFields return the file fields of req and its form fields
+func getMultipartFields(req interface{}, fileFields []string, style query.KeyStyle) ([]multipartFile, url.Values, error) {
+	files, encoded, err := getMultipartFiles(req, fileFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	values, err := query.ValuesWithStyle(req, "json", style)
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make([]string, 0, len(files)+len(encoded))
+	for _, file := range files {
+		names = append(names, file.name)
+	}
+	for name := range encoded {
+		names = append(names, name)
+	}
+	for key := range values {
+		for _, name := range names {
+			if key == name || strings.HasPrefix(key, name+"[") || strings.HasPrefix(key, name+".") {
+				delete(values, key)
+			}
+		}
+	}
+	for name, value := range encoded {
+		values[name] = []string{value}
+	}
+	return files, values, nil
+}
+
+// getProtoMultipartFields return the bytes fields of m named in fileFields as files, and its other fields
+// as form fields encoded following the google.api.http mapping like the query strings, see getQueryRequest
+func getProtoMultipartFields(m proto.Message, fileFields []string) ([]multipartFile, url.Values, error) {
+	values, err := query.ProtoValues(m)
+	if err != nil {
+		return nil, nil, err
+	}
+	var files []multipartFile
+	message := m.ProtoReflect()
+	for _, name := range fileFields {
+		fd := message.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if fd == nil || fd.Kind() != protoreflect.BytesKind || fd.IsList() {
+			continue
+		}
+		files = append(files, multipartFile{name: name, part: FilePart{Filename: name, Body: bytes.NewReader(message.Get(fd).Bytes())}})
+		delete(values, name)
+	}
+	return files, values, nil
+}
+
+// writeMultipart write the form fields then the files
+func writeMultipart(mw *multipart.Writer, values map[string][]string, files []multipartFile) error {
+	for key, vs := range values {
+		for _, v := range vs {
+			if err := mw.WriteField(key, v); err != nil {
+				return err
+			}
+		}
+	}
+	for _, file := range files {
+		contentType := file.part.ContentType
+		if len(contentType) == 0 {
+			contentType = ContentTypeOctetStream
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(file.name), escapeQuotes(file.part.Filename)))
+		header.Set(ContentType, contentType)
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if file.part.Body != nil {
+			if _, err = io.Copy(part, file.part.Body); err != nil {
+				return err
+			}
+		}
+	}
+	return mw.Close()
+}
+
+// getMultipartFiles return the file fields of req and the base64 values of its other non-nil []byte fields
+func getMultipartFiles(req interface{}, fileFields []string) ([]multipartFile, map[string]string, error) {
+	value := reflect.ValueOf(req)
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("req not struct:%v", value.Kind())
+	}
+	var files []multipartFile
+	encoded := make(map[string]string)
+	valType := value.Type()
+	for i := 0; i < value.NumField(); i++ {
+		field := valType.Field(i)
+		val := value.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		part, ok := getFilePart(val, field, name, fileFields)
+		if ok {
+			files = append(files, multipartFile{name: name, part: part})
+		} else if field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Uint8 && !val.IsNil() {
+			encoded[name] = base64.StdEncoding.EncodeToString(val.Bytes())
+		}
+	}
+	return files, encoded, nil
+}
+
+// getFilePart return the file of a field, ok is false if the field is not a file or is nil
+func getFilePart(val reflect.Value, field reflect.StructField, name string, fileFields []string) (FilePart, bool) {
+	switch {
+	case field.Type == filePartType:
+		return val.Interface().(FilePart), true
+	case field.Type.Kind() == reflect.Ptr && field.Type.Elem() == filePartType:
+		if val.IsNil() {
+			return FilePart{}, false
+		}
+		return *val.Interface().(*FilePart), true
+	case field.Type.Implements(readerType):
+		if (val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface) && val.IsNil() {
+			return FilePart{}, false
+		}
+		filename := name
+		if named, ok := val.Interface().(interface{ Name() string }); ok {
+			filename = filepath.Base(named.Name())
+		}
+		return FilePart{Filename: filename, Body: val.Interface().(io.Reader)}, true
+	case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Uint8:
+		if field.Tag.Get("multipart") != "file" && !containsString(fileFields, name) {
+			return FilePart{}, false
+		}
+		return FilePart{Filename: name, Body: bytes.NewReader(val.Bytes())}, true
+	}
+	return FilePart{}, false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
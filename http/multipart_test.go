@@ -0,0 +1,134 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type uploadReq struct {
+	Name     string    `json:"name"`
+	Tags     []string  `json:"tags"`
+	Avatar   *FilePart `json:"avatar"`
+	Resume   FilePart  `json:"resume"`
+	Raw      []byte    `json:"raw"`
+	Tagged   []byte    `json:"tagged" multipart:"file"`
+	Inline   []byte    `json:"inline"`
+	Document *strings.Reader
+}
+
+// newMultipartServer reply the form fields and the files of the multipart requests as a json map,
+// a file as "filename:content type:content"
+func newMultipartServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		reply := map[string]string{}
+		for k, v := range r.MultipartForm.Value {
+			reply[k] = strings.Join(v, ",")
+		}
+		for k, v := range r.MultipartForm.File {
+			file, _ := v[0].Open()
+			content, _ := ioutil.ReadAll(file)
+			reply[k] = v[0].Filename + ":" + v[0].Header.Get(ContentType) + ":" + string(content)
+		}
+		json.NewEncoder(w).Encode(reply)
+	}))
+}
+
+func Test_Multipart(t *testing.T) {
+	server := newMultipartServer()
+	defer server.Close()
+	client, err := NewClientConn(context.Background(), server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &uploadReq{
+		Name:     "prpc",
+		Tags:     []string{"a", "b"},
+		Avatar:   &FilePart{Filename: "me.png", ContentType: "image/png", Body: strings.NewReader("png")},
+		Resume:   FilePart{Filename: "cv.txt", Body: strings.NewReader("cv")},
+		Raw:      []byte("raw"),
+		Tagged:   []byte("tagged"),
+		Inline:   []byte("xy"),
+		Document: strings.NewReader("doc"),
+	}
+	reply := map[string]string{}
+	err = client.Invoke(context.Background(), "POST", "/upload", req, &reply, WithMultipart("raw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]string{
+		"name":     "prpc",
+		"tags":     "a,b",
+		"inline":   "eHk=",
+		"avatar":   "me.png:image/png:png",
+		"resume":   "cv.txt:application/octet-stream:cv",
+		"raw":      "raw:application/octet-stream:raw",
+		"tagged":   "tagged:application/octet-stream:tagged",
+		"Document": "Document:application/octet-stream:doc",
+	}
+	if len(reply) != len(expect) {
+		t.Fatalf("expect:%v,but get:%v", expect, reply)
+	}
+	for k, v := range expect {
+		if reply[k] != v {
+			t.Fatalf("expect %s:%v,but get:%v", k, v, reply[k])
+		}
+	}
+}
+
+func Test_MultipartProto(t *testing.T) {
+	server := newMultipartServer()
+	defer server.Close()
+	client, err := NewClientConn(context.Background(), server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		req        proto.Message
+		fileFields []string
+		expect     map[string]string
+	}{
+		{
+			req: &descriptorpb.FieldDescriptorProto{
+				Name:     proto.String("avatar"),
+				JsonName: proto.String("avatar"),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_BYTES.Enum(),
+			},
+			expect: map[string]string{"name": "avatar", "json_name": "avatar", "type": "TYPE_BYTES"},
+		},
+		{
+			req:        wrapperspb.Bytes([]byte("png")),
+			fileFields: []string{"value"},
+			expect:     map[string]string{"value": "value:application/octet-stream:png"},
+		},
+		{
+			req:    wrapperspb.Bytes([]byte("xy")),
+			expect: map[string]string{"value": "eHk="},
+		},
+	}
+	for _, testCase := range testCases {
+		reply := map[string]string{}
+		if err = client.Invoke(context.Background(), "POST", "/upload", testCase.req, &reply, WithMultipart(testCase.fileFields...)); err != nil {
+			t.Fatal(err)
+		}
+		if len(reply) != len(testCase.expect) {
+			t.Fatalf("expect:%v,but get:%v", testCase.expect, reply)
+		}
+		for k, v := range testCase.expect {
+			if reply[k] != v {
+				t.Fatalf("expect %s:%v,but get:%v", k, v, reply[k])
+			}
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrStreamNotSupported = errors.New("http client impl does not support streaming")
+)
+
+// StreamCallInterface http client impl that can return the response before reading its body,
+// the default http client impl implements it
+type StreamCallInterface interface {
+	Stream(ctx context.Context, addr string, api string, method string, req interface{}, opts ...CallOption) (*http.Request, *http.Response, error)
+}
+
+// Stream send the request and return the response with its body unread, the caller must close the body
+func (cc *defaultHttpClient) Stream(ctx context.Context, addr string, api string, method string, req interface{}, opts ...CallOption) (*http.Request, *http.Response, error) {
+	var request *http.Request
+	var err error
+	if method == http.MethodGet {
+		request, err = getQueryRequest(ctx, addr+api, req, opts...)
+	} else {
+		request, err = getBodyRequest(ctx, addr+api, method, req, opts...)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	client := http.Client{Transport: cc.transport}
+	response, err := client.Do(request)
+	return request, response, err
+}
+
+// InvokeStream send the request and return the response body without buffering it, for large downloads,
+// NDJSON and Server-Sent Events responses. Closing the body cancels the call.
+// The default timeout of the ClientConn is not applied, only the context deadline and WithCallTimeout,
+// and the interceptors are not called.
+func (cc *ClientConn) InvokeStream(ctx context.Context, method string, api string, req interface{}, opts ...CallOption) (io.ReadCloser, error) {
+	_, response, err := cc.invokeStream(ctx, method, api, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+// invokeStream send the request and return the call context and the response, closing its body cancels the call
+func (cc *ClientConn) invokeStream(ctx context.Context, method string, api string, req interface{}, opts ...CallOption) (context.Context, *http.Response, error) {
+	if cc.isClosed() {
+		return nil, nil, ErrClientConnClosing
+	}
+	call, ok := cc.connOption.httpCall.(StreamCallInterface)
+	if !ok {
+		return nil, nil, ErrStreamNotSupported
+	}
+	info := &CallInfo{DefaultHeader: cc.connOption.header}
+	if err := info.apply(opts); err != nil {
+		return nil, nil, err
+	}
+	api, err := convertApi(api, info.UrlParams)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the deadline of the call covers the pick, a call waiting for addresses fails once it is exceeded
+	ctx, cancel := callContext(ctx, info.TimeOut)
+	addr, err := cc.pickBaseUrl(ctx)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	_, response, err := call.Stream(ctx, addr, api, strings.ToUpper(method), req, info)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	info.after(response)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+		response.Body.Close()
+		cancel()
+		return nil, nil, fmt.Errorf("unexpected status:%s, body:%s", response.Status, message)
+	}
+	response.Body = &streamBody{ReadCloser: response.Body, cancel: cancel}
+	return ctx, response, nil
+}
+
+// streamBody cancel the call context once the body is closed
+type streamBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *streamBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// NDJSONDecoder decode a newline delimited JSON stream message by message
+type NDJSONDecoder struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+}
+
+// NewNDJSONDecoder return a NDJSONDecoder reading body
+func NewNDJSONDecoder(body io.ReadCloser) *NDJSONDecoder {
+	return &NDJSONDecoder{body: body, reader: bufio.NewReader(body)}
+}
+
+// Decode decode the next message into v, it returns io.EOF at the end of the stream
+func (d *NDJSONDecoder) Decode(v interface{}) error {
+	for {
+		line, err := d.reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			return json.Unmarshal(line, v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Close close the stream
+func (d *NDJSONDecoder) Close() error {
+	return d.body.Close()
+}
+
+// Event a Server-Sent Event
+type Event struct {
+	ID    string // the last event id
+	Event string // the event type, empty means "message"
+	Data  []byte
+	Retry time.Duration // the reconnection time sent by the server, zero if not sent
+}
+
+// SSEDecoder decode a Server-Sent Events stream event by event
+type SSEDecoder struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+	lastID string
+}
+
+// NewSSEDecoder return a SSEDecoder reading body
+func NewSSEDecoder(body io.ReadCloser) *SSEDecoder {
+	return &SSEDecoder{body: body, reader: bufio.NewReader(body)}
+}
+
+// Recv return the next event, it returns io.EOF at the end of the stream
+func (d *SSEDecoder) Recv() (*Event, error) {
+	event := &Event{}
+	var data bytes.Buffer
+	hasData := false
+	for {
+		line, err := d.reader.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			// an event not terminated by a blank line is discarded
+			return nil, err
+		}
+		line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+		if len(line) == 0 {
+			if !hasData {
+				event = &Event{}
+				continue
+			}
+			event.ID = d.lastID
+			event.Data = data.Bytes()
+			return event, nil
+		}
+		if line[0] == ':' {
+			continue
+		}
+		field, value := line, []byte(nil)
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], bytes.TrimPrefix(line[i+1:], []byte(" "))
+		}
+		switch string(field) {
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.Write(value)
+			hasData = true
+		case "event":
+			event.Event = string(value)
+		case "id":
+			if bytes.IndexByte(value, 0) < 0 {
+				d.lastID = string(value)
+			}
+		case "retry":
+			if retry, err := strconv.ParseUint(string(value), 10, 63); err == nil {
+				event.Retry = time.Duration(retry) * time.Millisecond
+			}
+		}
+	}
+}
+
+// Decode decode the JSON data of the next event into v, it returns io.EOF at the end of the stream
+func (d *SSEDecoder) Decode(v interface{}) error {
+	event, err := d.Recv()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(event.Data, v)
+}
+
+// Close close the stream
+func (d *SSEDecoder) Close() error {
+	return d.body.Close()
+}
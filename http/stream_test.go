@@ -0,0 +1,188 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type streamMessage struct {
+	Seq int `json:"seq"`
+}
+
+func newStreamServer(t *testing.T, done chan struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		switch r.URL.Path {
+		case "/upload":
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Write(body)
+		case "/ndjson":
+			for i := 1; i <= 3; i++ {
+				fmt.Fprintf(w, "{\"seq\":%d}\n\n", i)
+				flusher.Flush()
+			}
+		case "/sse":
+			io.WriteString(w, ": comment\nretry: 1000\nid: 7\ndata: {\"seq\":1}\n\nevent: tick\ndata: {\"seq\":\ndata: 2}\r\n\r\ndata: {\"seq\":3}")
+		case "/endless":
+			io.WriteString(w, "{\"seq\":1}\n")
+			flusher.Flush()
+			<-r.Context().Done()
+			close(done)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func Test_InvokeStream(t *testing.T) {
+	done := make(chan struct{})
+	server := newStreamServer(t, done)
+	defer server.Close()
+	client, err := NewClientConn(context.Background(), server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := client.InvokeStream(context.Background(), "POST", "/upload", nil, WithRequestBody(strings.NewReader("raw upload")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload, _ := ioutil.ReadAll(body)
+	body.Close()
+	if string(upload) != "raw upload" {
+		t.Fatalf("expect:%v,but get:%v", "raw upload", string(upload))
+	}
+
+	body, err = client.InvokeStream(context.Background(), "GET", "/ndjson", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ndjson := NewNDJSONDecoder(body)
+	for i := 1; ; i++ {
+		message := &streamMessage{}
+		err = ndjson.Decode(message)
+		if err == io.EOF && i == 4 {
+			break
+		}
+		if err != nil || message.Seq != i {
+			t.Fatalf("expect seq:%v,but get:%v, err:%v", i, message.Seq, err)
+		}
+	}
+	ndjson.Close()
+
+	body, err = client.InvokeStream(context.Background(), "GET", "/sse", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sse := NewSSEDecoder(body)
+	event, err := sse.Recv()
+	if err != nil || event.ID != "7" || event.Retry != time.Second || string(event.Data) != `{"seq":1}` {
+		t.Fatalf("unexpected event:%+v, err:%v", event, err)
+	}
+	event, err = sse.Recv()
+	if err != nil || event.ID != "7" || event.Event != "tick" || string(event.Data) != "{\"seq\":\n2}" {
+		t.Fatalf("unexpected event:%+v, err:%v", event, err)
+	}
+	// the last event is not terminated by a blank line
+	if _, err = sse.Recv(); err != io.EOF {
+		t.Fatalf("expect:%v,but get:%v", io.EOF, err)
+	}
+	sse.Close()
+
+	if _, err = client.InvokeStream(context.Background(), "GET", "/missing", nil); err == nil {
+		t.Fatal("expect error for status 404")
+	}
+
+	body, err = client.InvokeStream(context.Background(), "GET", "/endless", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ndjson = NewNDJSONDecoder(body)
+	if err = ndjson.Decode(&streamMessage{}); err != nil {
+		t.Fatal(err)
+	}
+	ndjson.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expect the call to be canceled on close")
+	}
+}
+
+func Test_ClientStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Accept", r.Header.Get(Accept))
+		if r.URL.Path == "/sse" {
+			w.Header().Set(ContentType, ContentTypeEventStream+"; charset=utf-8")
+			io.WriteString(w, "data: {\"seq\":1}\n\ndata: {\"seq\":2}\n\n")
+			return
+		}
+		w.Header().Set(ContentType, ContentTypeNDJSON)
+		io.WriteString(w, "{\"seq\":1}\n{\"seq\":2}\n")
+	}))
+	defer server.Close()
+	client, err := NewClientConn(context.Background(), server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, api := range []string{"/sse", "/ndjson"} {
+		stream, err := client.NewStream(context.Background(), "GET", api, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, _ := stream.Header()
+		if accept := header.Get("x-accept"); len(accept) != 1 || accept[0] != ContentTypeEventStream+", "+ContentTypeNDJSON {
+			t.Fatalf("unexpected accept header:%v", accept)
+		}
+		if err = stream.SendMsg(&streamMessage{}); err != ErrSendNotSupported {
+			t.Fatalf("expect:%v,but get:%v", ErrSendNotSupported, err)
+		}
+		for i := 1; ; i++ {
+			message := &streamMessage{}
+			err = stream.RecvMsg(message)
+			if err == io.EOF && i == 3 {
+				break
+			}
+			if err != nil || message.Seq != i {
+				t.Fatalf("%s expect seq:%v,but get:%v, err:%v", api, i, message.Seq, err)
+			}
+		}
+		if stream.Context().Err() == nil {
+			t.Fatal("expect the call to be released at the end of the stream")
+		}
+	}
+}
+
+func Test_ClientStreamTrailer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Status")
+		w.Header().Set(ContentType, ContentTypeNDJSON)
+		io.WriteString(w, "{\"seq\":1}\n")
+		w.Header().Set("X-Status", "done")
+	}))
+	defer server.Close()
+	client, err := NewClientConn(context.Background(), server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := client.NewStream(context.Background(), "GET", "/ndjson", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for err == nil {
+		err = stream.RecvMsg(&streamMessage{})
+	}
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	if status := stream.Trailer().Get("x-status"); len(status) != 1 || status[0] != "done" {
+		t.Fatalf("expect:[done],but get:%v", status)
+	}
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// TimeoutHeader carries the remaining time budget of a call to the server,
+	// encoded like the grpc-timeout header, e.g. "500m" for 500 milliseconds
+	TimeoutHeader = "Prpc-Timeout"
+	// maxTimeoutValue the encoded value has at most 8 digits
+	maxTimeoutValue = 100000000 - 1
+)
+
+// EncodeTimeout encode a timeout as the value of TimeoutHeader, rounded up to the unit used
+func EncodeTimeout(t time.Duration) string {
+	if t <= 0 {
+		return "0n"
+	}
+	units := []struct {
+		d    time.Duration
+		unit string
+	}{
+		{time.Nanosecond, "n"},
+		{time.Microsecond, "u"},
+		{time.Millisecond, "m"},
+		{time.Second, "S"},
+		{time.Minute, "M"},
+	}
+	for _, u := range units {
+		if value := (t + u.d - 1) / u.d; value <= maxTimeoutValue {
+			return strconv.FormatInt(int64(value), 10) + u.unit
+		}
+	}
+	return strconv.FormatInt(int64((t+time.Hour-1)/time.Hour), 10) + "H"
+}
+
+// DecodeTimeout decode the value of TimeoutHeader, servers can use it to honor the caller deadline
+func DecodeTimeout(s string) (time.Duration, error) {
+	if len(s) < 2 || len(s) > 9 {
+		return 0, fmt.Errorf("invalid timeout:%q", s)
+	}
+	var unit time.Duration
+	switch s[len(s)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, fmt.Errorf("invalid timeout unit:%q", s)
+	}
+	value, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid timeout value:%q", s)
+	}
+	return time.Duration(value) * unit, nil
+}
@@ -0,0 +1,142 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"github.com/classtorch/prpc/metadata"
+	"github.com/classtorch/prpc/resolver"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// emptyResolverBuilder build the resolvers never finding an address
+type emptyResolverBuilder struct {
+}
+
+func (b emptyResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn) (resolver.Resolver, error) {
+	return mockResolver{}, nil
+}
+
+func (b emptyResolverBuilder) Scheme() string {
+	return "empty"
+}
+
+func Test_EncodeTimeout(t *testing.T) {
+	testCases := []struct {
+		timeout time.Duration
+		value   string
+	}{
+		{timeout: 0, value: "0n"},
+		{timeout: 1500 * time.Microsecond, value: "1500000n"},
+		{timeout: 250 * time.Millisecond, value: "250000u"},
+		{timeout: 2 * time.Minute, value: "120000m"},
+		{timeout: 30 * time.Hour, value: "108000S"},
+	}
+	for _, testCase := range testCases {
+		value := EncodeTimeout(testCase.timeout)
+		if value != testCase.value {
+			t.Fatalf("expect:%v,but get:%v", testCase.value, value)
+		}
+		timeout, err := DecodeTimeout(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if timeout != testCase.timeout {
+			t.Fatalf("expect:%v,but get:%v", testCase.timeout, timeout)
+		}
+	}
+	for _, value := range []string{"", "1", "1x", "-1m", "123456789m"} {
+		if _, err := DecodeTimeout(value); err == nil {
+			t.Fatalf("expect error for:%q", value)
+		}
+	}
+}
+
+func Test_CallDeadline(t *testing.T) {
+	timeouts := make(chan time.Duration, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout, _ := DecodeTimeout(r.Header.Get(TimeoutHeader))
+		timeouts <- timeout
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClientConn(context.Background(), server.Listener.Addr().String(), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	reply := &struct{}{}
+	if err = client.Invoke(ctx, "GET", "/fast", nil, reply); err != nil {
+		t.Fatal(err)
+	}
+	if timeout := <-timeouts; timeout <= 0 || timeout > 300*time.Millisecond {
+		t.Fatalf("expect context deadline to win, but get:%v", timeout)
+	}
+
+	if err = client.Invoke(context.Background(), "GET", "/slow", nil, reply, WithCallTimeout(50*time.Millisecond)); err == nil {
+		t.Fatal("expect deadline exceeded")
+	}
+	if timeout := <-timeouts; timeout <= 0 || timeout > 50*time.Millisecond {
+		t.Fatalf("expect call timeout to win, but get:%v", timeout)
+	}
+}
+
+func Test_CallTimeoutCoversPick(t *testing.T) {
+	// the resolver never finds an address, the calls wait in the pick until their deadline
+	cc, err := NewClientConn(context.Background(), "empty://127.0.0.1/users", WithResolver(emptyResolverBuilder{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	start := time.Now()
+	err = cc.Invoke(context.Background(), "GET", "/users", nil, &struct{}{}, WithCallTimeout(50*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect:%v,but get:%v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expect:the call to fail after 50ms,but it took:%v", elapsed)
+	}
+	_, _, err = cc.invokeStream(context.Background(), "GET", "/users", nil, WithCallTimeout(50*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect:%v,but get:%v", context.DeadlineExceeded, err)
+	}
+}
+
+func Test_TimeoutHeaderNotOverridden(t *testing.T) {
+	values := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		values <- r.Header.Get(TimeoutHeader)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClientConn(context.Background(), server.Listener.Addr().String(), WithTimeout(0),
+		WithDefaultHeader(http.Header{TimeoutHeader: []string{"1H"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), TimeoutHeader, "2H")
+	header := WithHTTPHeader(http.Header{TimeoutHeader: []string{"3H"}})
+
+	// the headers set by the ClientConn, the metadata and the call options never replace the deadline of the call
+	if err = client.Invoke(ctx, "GET", "/users", nil, &struct{}{}, header, WithCallTimeout(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if timeout, err := DecodeTimeout(<-values); err != nil || timeout <= 0 || timeout > time.Second {
+		t.Fatalf("expect:the deadline of the call,but get:%v, err:%v", timeout, err)
+	}
+	// nor announce one when the call has none
+	if err = client.Invoke(ctx, "GET", "/users", nil, &struct{}{}, header); err != nil {
+		t.Fatal(err)
+	}
+	if value := <-values; len(value) > 0 {
+		t.Fatalf("expect:no timeout header,but get:%v", value)
+	}
+}
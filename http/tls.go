@@ -0,0 +1,180 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/classtorch/prpc/logger"
+)
+
+// serverNameTransport send requests to https addresses whose ServerName was set by their resolver
+// through a copy of the base transport verifying that ServerName
+type serverNameTransport struct {
+	base        *http.Transport
+	serverNames sync.Map // "host:port" -> ServerName
+
+	mu         sync.Mutex
+	transports map[string]*http.Transport // ServerName -> transport
+}
+
+func newServerNameTransport(base *http.Transport) *serverNameTransport {
+	return &serverNameTransport{base: base, transports: make(map[string]*http.Transport)}
+}
+
+// setServerName set the ServerName verified when calling addr over https,
+// an empty serverName verifies the host of addr again
+func (t *serverNameTransport) setServerName(addr string, serverName string) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "443")
+	}
+	if len(serverName) == 0 {
+		t.serverNames.Delete(addr)
+		return
+	}
+	if name, ok := t.serverNames.Load(addr); ok && name == serverName {
+		return
+	}
+	t.serverNames.Store(addr, serverName)
+}
+
+// transport return the transport verifying serverName
+func (t *serverNameTransport) transport(serverName string) *http.Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	transport, ok := t.transports[serverName]
+	if !ok {
+		transport = t.base.Clone()
+		transport.TLSClientConfig.ServerName = serverName
+		t.transports[serverName] = transport
+	}
+	return transport
+}
+
+func (t *serverNameTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.URL.Scheme == "https" {
+		if name, ok := t.serverNames.Load(canonicalAddr(request.URL)); ok {
+			return t.transport(name.(string)).RoundTrip(request)
+		}
+	}
+	return t.base.RoundTrip(request)
+}
+
+// CloseIdleConnections close the idle connections of all transports
+func (t *serverNameTransport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, transport := range t.transports {
+		transport.CloseIdleConnections()
+	}
+}
+
+// newTLSConfig build the TLS config of a ClientConn from its connect options
+func newTLSConfig(o connectOption) (*tls.Config, error) {
+	config := &tls.Config{}
+	if o.tlsConfig != nil {
+		config = o.tlsConfig.Clone()
+	}
+	if o.rootCAs != nil {
+		config.RootCAs = o.rootCAs
+	}
+	if len(o.rootCAFile) > 0 {
+		pem, err := ioutil.ReadFile(o.rootCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in root CA file:%s", o.rootCAFile)
+		}
+		config.RootCAs = pool
+	}
+	if len(o.clientCertFile) > 0 || len(o.clientKeyFile) > 0 {
+		reloader, err := newCertReloader(o.clientCertFile, o.clientKeyFile, o.log)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = nil
+		config.GetClientCertificate = reloader.getClientCertificate
+	}
+	return config, nil
+}
+
+// certReloader load a client certificate and reload it when its files change,
+// established connections keep the certificate they were dialed with
+type certReloader struct {
+	certFile string
+	keyFile  string
+	log      logger.Logger
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+func newCertReloader(certFile, keyFile string, log logger.Logger) (*certReloader, error) {
+	if len(certFile) == 0 || len(keyFile) == 0 {
+		return nil, errors.New("client certificate needs both cert file and key file")
+	}
+	r := &certReloader{certFile: certFile, keyFile: keyFile, log: log}
+	certModTime, keyModTime, err := r.modTimes()
+	if err != nil {
+		return nil, err
+	}
+	if err = r.load(certModTime, keyModTime); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// modTimes return the modification time of the cert and key files
+func (r *certReloader) modTimes() (time.Time, time.Time, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
+
+// load load the certificate, the caller must hold r.mu or own r exclusively
+func (r *certReloader) load(certModTime, keyModTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.certModTime, r.keyModTime = certModTime, keyModTime
+	return nil
+}
+
+// getClientCertificate implements tls.Config.GetClientCertificate,
+// the previous certificate is kept if the changed files can not be loaded
+func (r *certReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	certModTime, keyModTime, err := r.modTimes()
+	if err != nil {
+		r.log.Error("stat client certificate, keep the loaded one", "cert", r.certFile, "err", err)
+		return r.cert, nil
+	}
+	if certModTime.Equal(r.certModTime) && keyModTime.Equal(r.keyModTime) {
+		return r.cert, nil
+	}
+	if err = r.load(certModTime, keyModTime); err != nil {
+		r.log.Error("reload client certificate, keep the loaded one", "cert", r.certFile, "err", err)
+	}
+	return r.cert, nil
+}
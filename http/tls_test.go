@@ -0,0 +1,139 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/classtorch/prpc/resolver"
+)
+
+type tlsResolverBuilder struct {
+	address resolver.Address
+}
+
+func (b tlsResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn) (resolver.Resolver, error) {
+	cc.UpdateState(resolver.State{Addresses: []resolver.Address{b.address}})
+	return mockResolver{}, nil
+}
+
+func (b tlsResolverBuilder) Scheme() string {
+	return "tls"
+}
+
+type tlsReply struct {
+	CommonName string `json:"cn"`
+}
+
+// newCert issue a certificate signed by parent, self-signed if parent is nil
+func newCert(t *testing.T, cn string, dnsNames []string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	return cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func Test_MutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ca, caKey, caPem, _ := newCert(t, "ca", nil, nil, nil)
+	_, _, serverPem, serverKeyPem := newCert(t, "server", []string{"backend.internal"}, ca, caKey)
+	_, _, clientPem, clientKeyPem := newCert(t, "client-1", nil, ca, caKey)
+	caFile, certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	ioutil.WriteFile(caFile, caPem, 0600)
+	ioutil.WriteFile(certFile, clientPem, 0600)
+	ioutil.WriteFile(keyFile, clientKeyPem, 0600)
+
+	serverCert, err := tls.X509KeyPair(serverPem, serverKeyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"cn":"` + r.TLS.PeerCertificates[0].Subject.CommonName + `"}`))
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{serverCert}, ClientCAs: pool, ClientAuth: tls.RequireAndVerifyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	address := resolver.Address{Addr: strings.TrimPrefix(server.URL, "https://"), ServerName: "backend.internal"}
+	client, err := NewClientConn(context.Background(), "tls:///backend", WithResolver(tlsResolverBuilder{address: address}),
+		WithSecure(true), WithRootCAFile(caFile), WithClientCertificate(certFile, keyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := &tlsReply{}
+	if err = client.Invoke(context.Background(), "GET", "/whoami", nil, reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.CommonName != "client-1" {
+		t.Fatalf("expect cn:client-1 but get:%s", reply.CommonName)
+	}
+
+	// rotate the client certificate, new connections use it
+	_, _, clientPem, clientKeyPem = newCert(t, "client-2", nil, ca, caKey)
+	ioutil.WriteFile(certFile, clientPem, 0600)
+	ioutil.WriteFile(keyFile, clientKeyPem, 0600)
+	later := time.Now().Add(time.Second)
+	os.Chtimes(certFile, later, later)
+	os.Chtimes(keyFile, later, later)
+	client.transport.CloseIdleConnections()
+	if err = client.Invoke(context.Background(), "GET", "/whoami", nil, reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.CommonName != "client-2" {
+		t.Fatalf("expect cn:client-2 but get:%s", reply.CommonName)
+	}
+}
+
+func Test_ServerNameCleared(t *testing.T) {
+	transport := newServerNameTransport(&http.Transport{TLSClientConfig: &tls.Config{}})
+	transport.setServerName("10.0.0.1", "backend.internal")
+	if name, ok := transport.serverNames.Load("10.0.0.1:443"); !ok || name != "backend.internal" {
+		t.Fatalf("expect:backend.internal,but get:%v", name)
+	}
+	// the resolver no longer sends a ServerName for the address
+	transport.setServerName("10.0.0.1", "")
+	if name, ok := transport.serverNames.Load("10.0.0.1:443"); ok {
+		t.Fatalf("expect:no ServerName,but get:%v", name)
+	}
+}
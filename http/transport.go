@@ -0,0 +1,228 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"golang.org/x/net/http2"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"sync/atomic"
+)
+
+// HostPoolStats connection pool statistics of a host,
+// over HTTP/2 InFlight/Open is the number of streams multiplexed on a connection
+type HostPoolStats struct {
+	Open          int64 // connections currently open
+	Dialed        int64 // connections dialed since the ClientConn creation
+	Requests      int64 // requests sent since the ClientConn creation
+	HTTP2Requests int64 // requests answered over HTTP/2
+	Reused        int64 // requests sent over a reused connection
+	InFlight      int64 // requests whose response body is not closed yet
+}
+
+// poolStats connection pool statistics of all hosts, keyed by "host:port"
+type poolStats struct {
+	mu    sync.Mutex
+	hosts map[string]*HostPoolStats
+}
+
+func newPoolStats() *poolStats {
+	return &poolStats{hosts: make(map[string]*HostPoolStats)}
+}
+
+// host return the statistics of addr, they are updated atomically
+func (ps *poolStats) host(addr string) *HostPoolStats {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	stats, ok := ps.hosts[addr]
+	if !ok {
+		stats = &HostPoolStats{}
+		ps.hosts[addr] = stats
+	}
+	return stats
+}
+
+// snapshot return a copy of the statistics, unix socket hosts are keyed by "unix:path"
+func (ps *poolStats) snapshot() map[string]HostPoolStats {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	result := make(map[string]HostPoolStats, len(ps.hosts))
+	for addr, stats := range ps.hosts {
+		if socket, ok := unixSocket(addr); ok {
+			addr = unixScheme + ":" + socket
+		}
+		result[addr] = HostPoolStats{
+			Open:          atomic.LoadInt64(&stats.Open),
+			Dialed:        atomic.LoadInt64(&stats.Dialed),
+			Requests:      atomic.LoadInt64(&stats.Requests),
+			HTTP2Requests: atomic.LoadInt64(&stats.HTTP2Requests),
+			Reused:        atomic.LoadInt64(&stats.Reused),
+			InFlight:      atomic.LoadInt64(&stats.InFlight),
+		}
+	}
+	return result
+}
+
+// Protocol http protocol spoken by a ClientConn
+type Protocol int
+
+const (
+	// ProtocolAuto HTTP/2 over TLS when the server supports it, otherwise HTTP/1.1
+	ProtocolAuto Protocol = iota
+	// ProtocolHTTP1 always HTTP/1.1
+	ProtocolHTTP1
+	// ProtocolHTTP2 HTTP/2 over TLS and cleartext HTTP/2 (h2c with prior knowledge) over plain http,
+	// the servers must support it
+	ProtocolHTTP2
+)
+
+// clientTransport the transport owned by a ClientConn
+type clientTransport struct {
+	*serverNameTransport
+	// plain http requests are sent over h2c when set
+	h2c *http2.Transport
+}
+
+func (t *clientTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if t.h2c != nil && request.URL.Scheme == "http" {
+		return t.h2c.RoundTrip(request)
+	}
+	return t.serverNameTransport.RoundTrip(request)
+}
+
+// CloseIdleConnections close the idle connections of all transports
+func (t *clientTransport) CloseIdleConnections() {
+	t.serverNameTransport.CloseIdleConnections()
+	if t.h2c != nil {
+		t.h2c.CloseIdleConnections()
+	}
+}
+
+// newTransport build the transport owned by a ClientConn from its connect options
+func newTransport(o connectOption, stats *poolStats) (*clientTransport, error) {
+	var transport *http.Transport
+	if o.transport != nil {
+		transport = o.transport.Clone()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	if o.maxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = o.maxIdleConnsPerHost
+	}
+	if o.tlsConfig == nil && transport.TLSClientConfig != nil {
+		o.tlsConfig = transport.TLSClientConfig
+	}
+	tlsConfig, err := newTLSConfig(o)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+	if o.proxy != nil {
+		transport.Proxy = o.proxy
+	}
+	if o.dialer != nil {
+		transport.DialContext = o.dialer.DialContext
+	}
+	transport = withUnixDialer(transport)
+	dial := transport.DialContext
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		hostStats := stats.host(addr)
+		atomic.AddInt64(&hostStats.Dialed, 1)
+		atomic.AddInt64(&hostStats.Open, 1)
+		return &countedConn{Conn: conn, stats: hostStats}, nil
+	}
+	result := &clientTransport{}
+	switch o.protocol {
+	case ProtocolHTTP1:
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	case ProtocolHTTP2:
+		transport.ForceAttemptHTTP2 = true
+		result.h2c = &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return transport.DialContext(ctx, network, addr)
+			},
+		}
+	}
+	result.serverNameTransport = newServerNameTransport(transport)
+	return result, nil
+}
+
+// countedConn decrease the open connections of its host when closed
+type countedConn struct {
+	net.Conn
+	stats *HostPoolStats
+	once  sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() {
+		atomic.AddInt64(&c.stats.Open, -1)
+	})
+	return c.Conn.Close()
+}
+
+// countedRoundTripper count the requests of each host
+type countedRoundTripper struct {
+	base  http.RoundTripper
+	stats *poolStats
+}
+
+func (rt *countedRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	hostStats := rt.stats.host(canonicalAddr(request.URL))
+	atomic.AddInt64(&hostStats.Requests, 1)
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.AddInt64(&hostStats.Reused, 1)
+			}
+		},
+	}
+	request = request.WithContext(httptrace.WithClientTrace(request.Context(), trace))
+	atomic.AddInt64(&hostStats.InFlight, 1)
+	response, err := rt.base.RoundTrip(request)
+	if err != nil {
+		atomic.AddInt64(&hostStats.InFlight, -1)
+		return nil, err
+	}
+	if response.ProtoMajor == 2 {
+		atomic.AddInt64(&hostStats.HTTP2Requests, 1)
+	}
+	response.Body = &countedBody{ReadCloser: response.Body, stats: hostStats}
+	return response, nil
+}
+
+// countedBody decrease the in flight requests of its host when closed
+type countedBody struct {
+	io.ReadCloser
+	stats *HostPoolStats
+	once  sync.Once
+}
+
+func (b *countedBody) Close() error {
+	b.once.Do(func() {
+		atomic.AddInt64(&b.stats.InFlight, -1)
+	})
+	return b.ReadCloser.Close()
+}
+
+// canonicalAddr return "host:port" of u, the port defaults to the one of the scheme
+func canonicalAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
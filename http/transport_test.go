@@ -0,0 +1,70 @@
+package http
+
+import (
+	"context"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_PoolStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	target := strings.TrimPrefix(server.URL, "http://")
+	client, err := NewClientConn(context.Background(), target, WithMaxIdleConnsPerHost(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err = client.Invoke(context.Background(), "GET", "/users", nil, &struct{}{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := client.PoolStats()[target]
+	if stats.Dialed != 1 || stats.Open != 1 || stats.Requests != 3 || stats.Reused != 2 || stats.InFlight != 0 {
+		t.Fatalf("unexpected stats:%+v", stats)
+	}
+	if err = client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if stats = client.PoolStats()[target]; stats.Open != 0 {
+		t.Fatalf("expect no open connection after close but get:%+v", stats)
+	}
+	if err = client.Invoke(context.Background(), "GET", "/users", nil, &struct{}{}); err != ErrClientConnClosing {
+		t.Fatalf("expect err:ErrClientConnClosing but get:%v", err)
+	}
+}
+
+func Test_H2C(t *testing.T) {
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"proto":"` + r.Proto + `"}`))
+	}), &http2.Server{}))
+	defer server.Close()
+
+	target := strings.TrimPrefix(server.URL, "http://")
+	client, err := NewClientConn(context.Background(), target, WithProtocol(ProtocolHTTP2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	for i := 0; i < 3; i++ {
+		reply := &struct {
+			Proto string `json:"proto"`
+		}{}
+		if err = client.Invoke(context.Background(), "GET", "/users", nil, reply); err != nil {
+			t.Fatal(err)
+		}
+		if reply.Proto != "HTTP/2.0" {
+			t.Fatalf("expect proto:HTTP/2.0 but get:%s", reply.Proto)
+		}
+	}
+	if stats := client.PoolStats()[target]; stats.Dialed != 1 || stats.HTTP2Requests != 3 {
+		t.Fatalf("unexpected stats:%+v", stats)
+	}
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/classtorch/prpc/resolver"
+)
+
+const (
+	unixScheme         = "unix"
+	unixAbstractScheme = "unix-abstract"
+	// unixHostSuffix suffix of the url host standing for a unix socket
+	unixHostSuffix = ".unix"
+	// unixHostHeader Host header sent to a unix socket
+	unixHostHeader = "localhost"
+)
+
+// isUnixTarget whether the scheme is a unix socket one
+func isUnixTarget(scheme string) bool {
+	return scheme == unixScheme || scheme == unixAbstractScheme
+}
+
+// parseUnixAddr return the socket path of a "unix:path", "unix:///abs/path" or "unix-abstract:name" address
+func parseUnixAddr(addr string) (string, bool) {
+	if !strings.HasPrefix(addr, unixScheme) {
+		return "", false
+	}
+	target, err := resolver.ParseTarget(addr)
+	if err != nil || !isUnixTarget(target.Scheme) {
+		return "", false
+	}
+	socket := target.URL.Opaque
+	if socket == "" {
+		socket = target.URL.Path
+	}
+	if target.Scheme == unixAbstractScheme {
+		socket = "@" + socket
+	}
+	return socket, len(socket) > 0
+}
+
+// unixHost encode the socket path as url host, every socket gets its own connection pool in http.Transport
+func unixHost(socket string) string {
+	return hex.EncodeToString([]byte(socket)) + unixHostSuffix
+}
+
+// unixSocket decode the socket path from a host encoded by unixHost, the port if any is ignored
+func unixSocket(host string) (string, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if !strings.HasSuffix(host, unixHostSuffix) {
+		return "", false
+	}
+	socket, err := hex.DecodeString(strings.TrimSuffix(host, unixHostSuffix))
+	if err != nil {
+		return "", false
+	}
+	return string(socket), true
+}
+
+// baseUrl return the url prefix of addr, unix socket addresses are always plain http
+func baseUrl(addr string, secure bool) string {
+	if socket, ok := parseUnixAddr(addr); ok {
+		return "http://" + unixHost(socket)
+	}
+	if secure {
+		return "https://" + addr
+	}
+	return "http://" + addr
+}
+
+// withUnixDialer make transport dial unix socket hosts through the unix network and never proxy them
+func withUnixDialer(transport *http.Transport) *http.Transport {
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if socket, ok := unixSocket(addr); ok {
+			return dial(ctx, "unix", socket)
+		}
+		return dial(ctx, network, addr)
+	}
+	proxy := transport.Proxy
+	if proxy != nil {
+		transport.Proxy = func(request *http.Request) (*url.URL, error) {
+			if _, ok := unixSocket(request.URL.Host); ok {
+				return nil, nil
+			}
+			return proxy(request)
+		}
+	}
+	return transport
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type unixReply struct {
+	Host string `json:"host"`
+	Path string `json:"path"`
+}
+
+func Test_UnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "sidecar.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"host":"` + r.Host + `","path":"` + r.URL.Path + `"}`))
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	client, err := NewClientConn(context.Background(), "unix://"+socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := &unixReply{}
+	err = client.Invoke(context.Background(), "GET", "/users/{uid}", nil, reply, WithUrlParams(map[string]string{"uid": "123"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Host != unixHostHeader || reply.Path != "/users/123" {
+		t.Fatalf("unexpected reply:%+v", reply)
+	}
+}
+
+func Test_ParseUnixAddr(t *testing.T) {
+	testCases := []struct {
+		addr   string
+		socket string
+		ok     bool
+	}{
+		{addr: "unix:///tmp/sidecar.sock", socket: "/tmp/sidecar.sock", ok: true},
+		{addr: "unix:sidecar.sock", socket: "sidecar.sock", ok: true},
+		{addr: "unix-abstract:sidecar", socket: "@sidecar", ok: true},
+		{addr: "127.0.0.1:8000", ok: false},
+		{addr: "unixhost:8000", ok: false},
+	}
+	for _, tCase := range testCases {
+		socket, ok := parseUnixAddr(tCase.addr)
+		if socket != tCase.socket || ok != tCase.ok {
+			t.Fatalf("addr:%s expect:%s,%v but get:%s,%v", tCase.addr, tCase.socket, tCase.ok, socket, ok)
+		}
+		if ok {
+			if decoded, _ := unixSocket(unixHost(socket) + ":80"); decoded != socket {
+				t.Fatalf("expect:%s but get:%s", socket, decoded)
+			}
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package prpc
+
+import (
+	"context"
+	"github.com/classtorch/prpc/http"
+	"github.com/classtorch/prpc/metadata"
+	grpcRaw "google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/stats"
+	nethttp "net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// Transport the protocol of a call
+type Transport string
+
+const (
+	TransportHttp Transport = "http"
+	TransportGrpc Transport = "grpc"
+)
+
+// CallInfo the transport-neutral view of a unary call
+type CallInfo struct {
+	Transport Transport
+	// FullMethod the grpc full method, /package.Service/Method, or the http verb and api, GET /users/{uid}
+	FullMethod string
+	// Verb the http method, empty for grpc calls
+	Verb string
+	// Path the http api before the url params are replaced, or the grpc full method
+	Path string
+	// Metadata the outgoing metadata of the context, sent as grpc metadata or http headers
+	Metadata metadata.MD
+	// Peer the address of the server, set once the invoker returns if a server was picked
+	Peer string
+	// StatusCode the http status code, set once the invoker returns if a response was received,
+	// it is 0 for grpc calls whose status is given by the error
+	StatusCode int
+}
+
+// UnaryInvoker invoke the call, interceptors may change ctx, req and reply, the CallInfo is for reading only
+type UnaryInvoker func(ctx context.Context, info *CallInfo, req interface{}, reply interface{}) error
+
+// UnaryInterceptor intercept http and grpc unary calls alike, it must call invoker to continue the call
+type UnaryInterceptor func(ctx context.Context, info *CallInfo, req interface{}, reply interface{}, invoker UnaryInvoker) error
+
+// HttpInterceptor adapt interceptor to a http Interceptor
+func HttpInterceptor(interceptor UnaryInterceptor) http.Interceptor {
+	return func(ctx context.Context, req interface{}, reply interface{}, httpRequest *nethttp.Request, httpResponse *nethttp.Response, cc *http.ClientConn, invoker http.Invoker, option ...http.CallOption) error {
+		verb := strings.ToUpper(httpRequest.Method)
+		info := &CallInfo{
+			Transport:  TransportHttp,
+			FullMethod: verb + " " + httpRequest.URL.Path,
+			Verb:       verb,
+			Path:       httpRequest.URL.Path,
+		}
+		info.Metadata, _ = metadata.FromOutgoingContext(ctx)
+		return interceptor(ctx, info, req, reply, func(ctx context.Context, info *CallInfo, req interface{}, reply interface{}) error {
+			err := invoker(ctx, req, reply, httpRequest, httpResponse, cc, option...)
+			info.Peer = httpPeer(httpRequest)
+			info.StatusCode = httpResponse.StatusCode
+			return err
+		})
+	}
+}
+
+// httpPeer return the host of the request sent, or of the base url picked if the request was not sent
+func httpPeer(httpRequest *nethttp.Request) string {
+	if httpRequest.URL != nil && len(httpRequest.URL.Host) > 0 {
+		return httpRequest.URL.Host
+	}
+	if baseUrl, err := url.Parse(httpRequest.Host); err == nil {
+		return baseUrl.Host
+	}
+	return ""
+}
+
+// GrpcUnaryInterceptor adapt interceptor to a grpc UnaryClientInterceptor
+func GrpcUnaryInterceptor(interceptor UnaryInterceptor) grpcRaw.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpcRaw.ClientConn, invoker grpcRaw.UnaryInvoker, opts ...grpcRaw.CallOption) error {
+		info := &CallInfo{
+			Transport:  TransportGrpc,
+			FullMethod: method,
+			Path:       method,
+		}
+		info.Metadata, _ = metadata.FromOutgoingContext(ctx)
+		return interceptor(ctx, info, req, reply, func(ctx context.Context, info *CallInfo, req interface{}, reply interface{}) error {
+			var p peer.Peer
+			err := invoker(ctx, method, req, reply, cc, append(opts[:len(opts):len(opts)], grpcRaw.Peer(&p))...)
+			if p.Addr != nil {
+				info.Peer = p.Addr.String()
+			}
+			return err
+		})
+	}
+}
+
+type attemptKey struct{}
+
+// attemptTrace the attempt hooks of a call and the number of its grpc attempts in flight
+type attemptTrace struct {
+	attempt  func(hedged bool)
+	inFlight int32
+}
+
+// WithAttemptTrace return a copy of ctx calling attempt each time the call is sent, the attempt hooks already
+// carried by ctx are called first. The attempts of the grpc calls are counted by the stats.Handler of
+// GrpcAttemptStatsHandler, which ClientConn.NewGrpcClientConn installs, an attempt is hedged when it begins
+// while another attempt of the call is in flight. The attempts of the http calls are counted by the connections
+// they get and are never hedged
+func WithAttemptTrace(ctx context.Context, info *CallInfo, attempt func(hedged bool)) context.Context {
+	switch info.Transport {
+	case TransportGrpc:
+		if outer, ok := ctx.Value(attemptKey{}).(*attemptTrace); ok {
+			inner := attempt
+			attempt = func(hedged bool) {
+				outer.attempt(hedged)
+				inner(hedged)
+			}
+		}
+		return context.WithValue(ctx, attemptKey{}, &attemptTrace{attempt: attempt})
+	case TransportHttp:
+		return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			GetConn: func(string) {
+				attempt(false)
+			},
+		})
+	}
+	return ctx
+}
+
+// GrpcAttemptStatsHandler return a grpc stats.Handler calling the attempt hooks of WithAttemptTrace once per attempt
+// of the calls, the picks done again within an attempt are not counted
+func GrpcAttemptStatsHandler() stats.Handler {
+	return attemptStatsHandler{}
+}
+
+// attemptStatsHandler call the attempt hooks of the context on the Begin of each client attempt
+// and count the attempts in flight until their End
+type attemptStatsHandler struct{}
+
+func (attemptStatsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (attemptStatsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if !s.IsClient() {
+		return
+	}
+	trace, ok := ctx.Value(attemptKey{}).(*attemptTrace)
+	if !ok {
+		return
+	}
+	switch s.(type) {
+	case *stats.Begin:
+		trace.attempt(atomic.AddInt32(&trace.inFlight, 1) > 1)
+	case *stats.End:
+		atomic.AddInt32(&trace.inFlight, -1)
+	}
+}
+
+func (attemptStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (attemptStatsHandler) HandleConn(context.Context, stats.ConnStats) {}
+
+// chainUnaryInterceptors return an interceptor running interceptors in order, nil if there is none
+func chainUnaryInterceptors(interceptors []UnaryInterceptor) UnaryInterceptor {
+	switch len(interceptors) {
+	case 0:
+		return nil
+	case 1:
+		return interceptors[0]
+	}
+	return func(ctx context.Context, info *CallInfo, req interface{}, reply interface{}, invoker UnaryInvoker) error {
+		return interceptors[0](ctx, info, req, reply, getChainUnaryInvoker(interceptors, 0, invoker))
+	}
+}
+
+func getChainUnaryInvoker(interceptors []UnaryInterceptor, cur int, finalInvoker UnaryInvoker) UnaryInvoker {
+	if cur == len(interceptors)-1 {
+		return finalInvoker
+	}
+	return func(ctx context.Context, info *CallInfo, req interface{}, reply interface{}) error {
+		return interceptors[cur+1](ctx, info, req, reply, getChainUnaryInvoker(interceptors, cur+1, finalInvoker))
+	}
+}
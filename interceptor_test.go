@@ -0,0 +1,209 @@
+package prpc
+
+import (
+	"context"
+	"fmt"
+	"github.com/classtorch/prpc/grpc"
+	"github.com/classtorch/prpc/http"
+	"github.com/classtorch/prpc/metadata"
+	grpcRaw "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_UnaryInterceptor(t *testing.T) {
+	httpServer := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer httpServer.Close()
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpcRaw.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, health.NewServer())
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	var calls []string
+	interceptor := func(ctx context.Context, info *CallInfo, req interface{}, reply interface{}, invoker UnaryInvoker) error {
+		err := invoker(ctx, info, req, reply)
+		calls = append(calls, fmt.Sprintf("%s|%s|%s|%s|%v|%v", info.Transport, info.FullMethod, info.Verb, info.Path, info.Metadata.Get("tenant"), err))
+		return err
+	}
+	cc := NewClientConn()
+	defer cc.Close()
+	err := cc.NewHttpClientConn(context.Background(), httpServer.Listener.Addr().String(), http.WithInterceptor(HttpInterceptor(interceptor)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialer := func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	}
+	err = cc.NewGrpcClientConn(context.Background(), "bufnet", grpc.WithUnaryInterceptor(GrpcUnaryInterceptor(interceptor)),
+		grpc.WithOptions(grpcRaw.WithContextDialer(dialer), grpcRaw.WithTransportCredentials(insecure.NewCredentials())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "tenant", "acme")
+	if err = cc.HttpInvoke(ctx, "get", "/users/{uid}", nil, &struct{}{}, http.WithUrlParams(map[string]string{"uid": "1"})); err != nil {
+		t.Fatal(err)
+	}
+	if err = cc.GrpcInvoke(ctx, "/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		"http|GET /users/{uid}|GET|/users/{uid}|[acme]|<nil>",
+		"grpc|/grpc.health.v1.Health/Check||/grpc.health.v1.Health/Check|[acme]|<nil>",
+	}
+	if strings.Join(calls, ",") != strings.Join(expect, ",") {
+		t.Fatalf("expect:%v,but get:%v", expect, calls)
+	}
+}
+
+func Test_ClientConnUnaryInterceptor(t *testing.T) {
+	httpServer := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer httpServer.Close()
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpcRaw.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, health.NewServer())
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	var calls []string
+	named := func(name string) UnaryInterceptor {
+		return func(ctx context.Context, info *CallInfo, req interface{}, reply interface{}, invoker UnaryInvoker) error {
+			err := invoker(ctx, info, req, reply)
+			calls = append(calls, fmt.Sprintf("%s|%s|%s", name, info.FullMethod, info.Peer))
+			return err
+		}
+	}
+	// the transport interceptors run inside the prpc ones
+	httpInterceptor := func(ctx context.Context, req interface{}, reply interface{}, httpRequest *nethttp.Request, httpResponse *nethttp.Response, cc *http.ClientConn, invoker http.Invoker, option ...http.CallOption) error {
+		err := invoker(ctx, req, reply, httpRequest, httpResponse, cc, option...)
+		calls = append(calls, "http")
+		return err
+	}
+	grpcInterceptor := func(ctx context.Context, method string, req, reply interface{}, cc *grpcRaw.ClientConn, invoker grpcRaw.UnaryInvoker, opts ...grpcRaw.CallOption) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		calls = append(calls, "grpc")
+		return err
+	}
+	cc := NewClientConn(WithUnaryInterceptor(named("a")), WithUnaryInterceptor(named("b")))
+	defer cc.Close()
+	err := cc.NewHttpClientConn(context.Background(), httpServer.Listener.Addr().String(), http.WithInterceptor(httpInterceptor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialer := func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	}
+	err = cc.NewGrpcClientConn(context.Background(), "bufnet", grpc.WithUnaryInterceptor(grpcInterceptor),
+		grpc.WithOptions(grpcRaw.WithContextDialer(dialer), grpcRaw.WithTransportCredentials(insecure.NewCredentials())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = cc.HttpInvoke(context.Background(), "POST", "/users", nil, &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = cc.GrpcInvoke(context.Background(), "/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	httpAddr := httpServer.Listener.Addr().String()
+	expect := []string{
+		"http", "b|POST /users|" + httpAddr, "a|POST /users|" + httpAddr,
+		"grpc", "b|/grpc.health.v1.Health/Check|bufconn", "a|/grpc.health.v1.Health/Check|bufconn",
+	}
+	if strings.Join(calls, ",") != strings.Join(expect, ",") {
+		t.Fatalf("expect:%v,but get:%v", expect, calls)
+	}
+}
+
+func Test_GrpcAttemptTrace(t *testing.T) {
+	listener := bufconn.Listen(1024 * 1024)
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("slow", healthpb.HealthCheckResponse_SERVING)
+	slow := func(ctx context.Context, req interface{}, info *grpcRaw.UnaryServerInfo, handler grpcRaw.UnaryHandler) (interface{}, error) {
+		if check, ok := req.(*healthpb.HealthCheckRequest); ok && check.Service == "slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		return handler(ctx, req)
+	}
+	grpcServer := grpcRaw.NewServer(grpcRaw.UnaryInterceptor(slow))
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	var first, second, hedges int32
+	trace := func(ctx context.Context, info *CallInfo, req interface{}, reply interface{}, invoker UnaryInvoker) error {
+		ctx = WithAttemptTrace(ctx, info, func(bool) { atomic.AddInt32(&first, 1) })
+		ctx = WithAttemptTrace(ctx, info, func(hedged bool) {
+			atomic.AddInt32(&second, 1)
+			if hedged {
+				atomic.AddInt32(&hedges, 1)
+			}
+		})
+		return invoker(ctx, info, req, reply)
+	}
+	// send the slow checks twice, the second request while the first one is in flight
+	hedge := func(ctx context.Context, info *CallInfo, req interface{}, reply interface{}, invoker UnaryInvoker) error {
+		if check, ok := req.(*healthpb.HealthCheckRequest); !ok || check.Service != "slow" {
+			return invoker(ctx, info, req, reply)
+		}
+		hedgeInfo := *info
+		hedged := make(chan error, 1)
+		go func() {
+			hedged <- invoker(ctx, &hedgeInfo, req, &healthpb.HealthCheckResponse{})
+		}()
+		time.Sleep(10 * time.Millisecond)
+		err := invoker(ctx, info, req, reply)
+		if hedgedErr := <-hedged; err == nil {
+			err = hedgedErr
+		}
+		return err
+	}
+	cc := NewClientConn(WithUnaryInterceptor(trace), WithUnaryInterceptor(hedge))
+	defer cc.Close()
+	dialer := func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	}
+	retryPolicy := `{"methodConfig":[{"name":[{"service":"grpc.health.v1.Health"}],"retryPolicy":{"maxAttempts":3,
+		"initialBackoff":"0.01s","maxBackoff":"0.01s","backoffMultiplier":1,"retryableStatusCodes":["NOT_FOUND"]}}]}`
+	err := cc.NewGrpcClientConn(context.Background(), "bufnet",
+		grpc.WithOptions(grpcRaw.WithContextDialer(dialer), grpcRaw.WithTransportCredentials(insecure.NewCredentials()), grpcRaw.WithDefaultServiceConfig(retryPolicy)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = cc.GrpcInvoke(context.Background(), "/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	if first != 1 || second != 1 {
+		t.Fatalf("expect:1 attempt for each hook,but get:%d %d", first, second)
+	}
+	// the retries of the policy are attempts too, they are not hedged
+	err = cc.GrpcInvoke(context.Background(), "/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{Service: "unknown"}, &healthpb.HealthCheckResponse{})
+	if err == nil {
+		t.Fatal("expect err:NotFound")
+	}
+	if first != 4 || second != 4 || hedges != 0 {
+		t.Fatalf("expect:4 attempts for each hook and no hedge,but get:%d %d %d", first, second, hedges)
+	}
+	// the requests sent while another one is in flight are hedged
+	if err = cc.GrpcInvoke(context.Background(), "/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{Service: "slow"}, &healthpb.HealthCheckResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	if first != 6 || second != 6 || hedges != 1 {
+		t.Fatalf("expect:6 attempts for each hook and 1 hedge,but get:%d %d %d", first, second, hedges)
+	}
+}
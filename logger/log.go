@@ -18,6 +18,7 @@ package logger
 
 import "log"
 
+// Log an unstructured logger, see Logger for the structured one and FromLog to use a Log as a Logger
 type Log interface {
 	// Info logs to the INFO log.
 	Info(args ...interface{})
@@ -66,6 +67,8 @@ func (d defaultLogger) Errorf(format string, args ...interface{}) {
 }
 
 // NewDefaultLogger Creates a new Log
+//
+// Deprecated: use NewStdLogger, which writes the level and the fields of the logs
 func NewDefaultLogger(logger *log.Logger) Log {
 	return &defaultLogger{
 		logger: logger,
@@ -0,0 +1,246 @@
+package logger
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Level the severity of a log
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return "LEVEL(" + strconv.Itoa(int(l)) + ")"
+}
+
+const (
+	// ComponentKey the key of the name of the component logging, see Logger.Named
+	ComponentKey = "component"
+	// BadKey the key of a value without key, e.g. the last of an odd number of keyvals
+	BadKey = "!BADKEY"
+)
+
+// Logger a structured and leveled logger, keyvals are alternating keys and values,
+// e.g. log.Warn("resolver error", "target", target, "err", err)
+type Logger interface {
+	// Debug logs at LevelDebug
+	Debug(msg string, keyvals ...interface{})
+	// Info logs at LevelInfo
+	Info(msg string, keyvals ...interface{})
+	// Warn logs at LevelWarn
+	Warn(msg string, keyvals ...interface{})
+	// Error logs at LevelError
+	Error(msg string, keyvals ...interface{})
+	// With return a Logger adding keyvals to all its logs
+	With(keyvals ...interface{}) Logger
+	// Named return a Logger of the component name, the names of nested components are joined with dots,
+	// e.g. Named("http").Named("balancer") logs with component=http.balancer
+	Named(name string) Logger
+}
+
+// JoinName return the name of the component name nested in parent
+func JoinName(parent, name string) string {
+	if len(parent) == 0 {
+		return name
+	}
+	if len(name) == 0 {
+		return parent
+	}
+	return parent + "." + name
+}
+
+// KeyValue a field of a log
+type KeyValue struct {
+	Key   string
+	Value interface{}
+}
+
+// KeyValues return keyvals as fields, non-string keys are formatted with fmt.Sprint
+func KeyValues(keyvals ...interface{}) []KeyValue {
+	kvs := make([]KeyValue, 0, (len(keyvals)+1)/2)
+	for i := 0; i < len(keyvals); i += 2 {
+		key, value := keyValue(keyvals, i)
+		kvs = append(kvs, KeyValue{Key: key, Value: value})
+	}
+	return kvs
+}
+
+// Fields return keyvals as a map, non-string keys are formatted with fmt.Sprint
+func Fields(keyvals ...interface{}) map[string]interface{} {
+	fields := make(map[string]interface{}, (len(keyvals)+1)/2)
+	for i := 0; i < len(keyvals); i += 2 {
+		key, value := keyValue(keyvals, i)
+		fields[key] = value
+	}
+	return fields
+}
+
+// keyValue return the key and the value at i of keyvals
+func keyValue(keyvals []interface{}, i int) (string, interface{}) {
+	if i+1 == len(keyvals) {
+		return BadKey, keyvals[i]
+	}
+	key, ok := keyvals[i].(string)
+	if !ok {
+		key = fmt.Sprint(keyvals[i])
+	}
+	return key, keyvals[i+1]
+}
+
+// format format msg and keyvals as key=value pairs, values with spaces or quotes are quoted
+func format(level Level, name string, msg string, keyvals []interface{}) string {
+	var b strings.Builder
+	b.WriteString("level=")
+	b.WriteString(level.String())
+	if len(name) > 0 {
+		b.WriteString(" " + ComponentKey + "=")
+		b.WriteString(quote(name))
+	}
+	b.WriteString(" msg=")
+	b.WriteString(quote(msg))
+	for i := 0; i < len(keyvals); i += 2 {
+		key, value := keyValue(keyvals, i)
+		b.WriteString(" ")
+		b.WriteString(quote(key))
+		b.WriteString("=")
+		b.WriteString(quote(fmt.Sprint(value)))
+	}
+	return b.String()
+}
+
+func quote(s string) string {
+	if len(s) == 0 || strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// stdLogger a Logger writing to a log.Logger
+type stdLogger struct {
+	logger  *log.Logger
+	level   Level
+	name    string
+	keyvals []interface{}
+}
+
+// NewStdLogger return a Logger writing the logs of level and above to logger, as key=value pairs
+func NewStdLogger(logger *log.Logger, level Level) Logger {
+	return &stdLogger{logger: logger, level: level}
+}
+
+// Default return the default Logger of pRPC, writing the logs of LevelInfo and above to stderr
+func Default() Logger {
+	return NewStdLogger(log.New(os.Stderr, DefaultLogPrefix+" ", log.LstdFlags), LevelInfo)
+}
+
+func (s *stdLogger) log(level Level, msg string, keyvals []interface{}) {
+	if level < s.level {
+		return
+	}
+	if len(s.keyvals) > 0 {
+		keyvals = append(s.keyvals[:len(s.keyvals):len(s.keyvals)], keyvals...)
+	}
+	s.logger.Print(format(level, s.name, msg, keyvals))
+}
+
+func (s *stdLogger) Debug(msg string, keyvals ...interface{}) {
+	s.log(LevelDebug, msg, keyvals)
+}
+
+func (s *stdLogger) Info(msg string, keyvals ...interface{}) {
+	s.log(LevelInfo, msg, keyvals)
+}
+
+func (s *stdLogger) Warn(msg string, keyvals ...interface{}) {
+	s.log(LevelWarn, msg, keyvals)
+}
+
+func (s *stdLogger) Error(msg string, keyvals ...interface{}) {
+	s.log(LevelError, msg, keyvals)
+}
+
+func (s *stdLogger) With(keyvals ...interface{}) Logger {
+	c := *s
+	c.keyvals = append(s.keyvals[:len(s.keyvals):len(s.keyvals)], keyvals...)
+	return &c
+}
+
+func (s *stdLogger) Named(name string) Logger {
+	c := *s
+	c.name = JoinName(s.name, name)
+	return &c
+}
+
+// logAdapter a Logger writing to a Log, the debug logs are dropped as Log has no debug level
+type logAdapter struct {
+	log     Log
+	name    string
+	keyvals []interface{}
+}
+
+// FromLog return a Logger writing to log
+func FromLog(log Log) Logger {
+	return &logAdapter{log: log}
+}
+
+func (a *logAdapter) format(level Level, msg string, keyvals []interface{}) string {
+	if len(a.keyvals) > 0 {
+		keyvals = append(a.keyvals[:len(a.keyvals):len(a.keyvals)], keyvals...)
+	}
+	return format(level, a.name, msg, keyvals)
+}
+
+func (a *logAdapter) Debug(string, ...interface{}) {}
+
+func (a *logAdapter) Info(msg string, keyvals ...interface{}) {
+	a.log.Info(a.format(LevelInfo, msg, keyvals))
+}
+
+func (a *logAdapter) Warn(msg string, keyvals ...interface{}) {
+	a.log.Warn(a.format(LevelWarn, msg, keyvals))
+}
+
+func (a *logAdapter) Error(msg string, keyvals ...interface{}) {
+	a.log.Error(a.format(LevelError, msg, keyvals))
+}
+
+func (a *logAdapter) With(keyvals ...interface{}) Logger {
+	c := *a
+	c.keyvals = append(a.keyvals[:len(a.keyvals):len(a.keyvals)], keyvals...)
+	return &c
+}
+
+func (a *logAdapter) Named(name string) Logger {
+	c := *a
+	c.name = JoinName(a.name, name)
+	return &c
+}
+
+// NopLogger a Logger logging nothing
+type NopLogger struct{}
+
+func (NopLogger) Debug(string, ...interface{}) {}
+func (NopLogger) Info(string, ...interface{})  {}
+func (NopLogger) Warn(string, ...interface{})  {}
+func (NopLogger) Error(string, ...interface{}) {}
+func (n NopLogger) With(...interface{}) Logger { return n }
+func (n NopLogger) Named(string) Logger        { return n }
@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+)
+
+func Test_StdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewStdLogger(log.New(&buf, "", 0), LevelInfo)
+	l.Debug("dropped")
+	l = l.Named("http").With("target", "consul://127.0.0.1:8500/users")
+	l.Named("balancer").Warn("pick failed", "err", errors.New("no address available"), "attempt", 2)
+	l.Error("odd", "key")
+
+	expect := []string{
+		`level=WARN component=http.balancer msg="pick failed" target=consul://127.0.0.1:8500/users err="no address available" attempt=2`,
+		`level=ERROR component=http msg=odd target=consul://127.0.0.1:8500/users !BADKEY=key`,
+	}
+	if get := strings.TrimSpace(buf.String()); get != strings.Join(expect, "\n") {
+		t.Fatalf("expect:%v,but get:%v", expect, get)
+	}
+}
+
+func Test_FromLog(t *testing.T) {
+	var buf bytes.Buffer
+	l := FromLog(NewDefaultLogger(log.New(&buf, "", 0))).Named("grpc").With("target", "users")
+	l.Debug("dropped")
+	l.Info("resolved", "addresses", 3)
+
+	expect := `level=INFO component=grpc msg=resolved target=users addresses=3`
+	if get := strings.TrimSpace(buf.String()); get != expect {
+		t.Fatalf("expect:%v,but get:%v", expect, get)
+	}
+}
+
+func Test_Fields(t *testing.T) {
+	fields := Fields("a", 1, 2, "b", "c")
+	if len(fields) != 3 || fields["a"] != 1 || fields["2"] != "b" || fields[BadKey] != "c" {
+		t.Fatalf("expect:%v,but get:%v", map[string]interface{}{"a": 1, "2": "b", BadKey: "c"}, fields)
+	}
+}
@@ -0,0 +1,54 @@
+// Package logrus implements logger.Logger with a logrus.FieldLogger
+package logrus
+
+import (
+	"github.com/classtorch/prpc/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// logrusLogger a logger.Logger writing to a logrus.FieldLogger
+type logrusLogger struct {
+	logger logrus.FieldLogger
+	name   string
+}
+
+// NewLogger return a logger.Logger writing to l, e.g. a logrus.Logger or a logrus.Entry,
+// the names of the components are logged as the logger.ComponentKey field
+func NewLogger(l logrus.FieldLogger) logger.Logger {
+	return &logrusLogger{logger: l}
+}
+
+func (l *logrusLogger) entry(keyvals []interface{}) logrus.FieldLogger {
+	entry := l.logger
+	if len(l.name) > 0 {
+		entry = entry.WithField(logger.ComponentKey, l.name)
+	}
+	if len(keyvals) > 0 {
+		entry = entry.WithFields(logger.Fields(keyvals...))
+	}
+	return entry
+}
+
+func (l *logrusLogger) Debug(msg string, keyvals ...interface{}) {
+	l.entry(keyvals).Debug(msg)
+}
+
+func (l *logrusLogger) Info(msg string, keyvals ...interface{}) {
+	l.entry(keyvals).Info(msg)
+}
+
+func (l *logrusLogger) Warn(msg string, keyvals ...interface{}) {
+	l.entry(keyvals).Warn(msg)
+}
+
+func (l *logrusLogger) Error(msg string, keyvals ...interface{}) {
+	l.entry(keyvals).Error(msg)
+}
+
+func (l *logrusLogger) With(keyvals ...interface{}) logger.Logger {
+	return &logrusLogger{logger: l.logger.WithFields(logger.Fields(keyvals...)), name: l.name}
+}
+
+func (l *logrusLogger) Named(name string) logger.Logger {
+	return &logrusLogger{logger: l.logger, name: logger.JoinName(l.name, name)}
+}
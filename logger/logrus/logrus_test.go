@@ -0,0 +1,28 @@
+package logrus
+
+import (
+	"github.com/classtorch/prpc/logger"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"testing"
+)
+
+func Test_Logger(t *testing.T) {
+	l, hook := test.NewNullLogger()
+	l.SetLevel(logrus.InfoLevel)
+	log := NewLogger(l).Named("http").With("target", "users")
+	log.Debug("dropped")
+	log.Named("balancer").Warn("pick failed", "attempt", 2)
+
+	entries := hook.AllEntries()
+	if len(entries) != 1 {
+		t.Fatalf("expect:1 entry,but get:%v", entries)
+	}
+	entry := entries[0]
+	if entry.Level != logrus.WarnLevel || entry.Message != "pick failed" {
+		t.Fatalf("expect:warning pick failed,but get:%v %v", entry.Level, entry.Message)
+	}
+	if len(entry.Data) != 3 || entry.Data[logger.ComponentKey] != "http.balancer" || entry.Data["target"] != "users" || entry.Data["attempt"] != 2 {
+		t.Fatalf("expect:component=http.balancer target=users attempt=2,but get:%v", entry.Data)
+	}
+}
@@ -0,0 +1,2 @@
+// Package slog implements logger.Logger with a log/slog Logger, it needs go1.21 or later
+package slog
@@ -0,0 +1,57 @@
+//go:build go1.21
+// +build go1.21
+
+package slog
+
+import (
+	"context"
+	"github.com/classtorch/prpc/logger"
+	"log/slog"
+)
+
+// slogLogger a logger.Logger writing to a slog.Logger
+type slogLogger struct {
+	logger *slog.Logger
+	name   string
+}
+
+// NewLogger return a logger.Logger writing to l, the names of the components are logged
+// as the logger.ComponentKey attribute
+func NewLogger(l *slog.Logger) logger.Logger {
+	return &slogLogger{logger: l}
+}
+
+func (s *slogLogger) log(level slog.Level, msg string, keyvals []interface{}) {
+	ctx := context.Background()
+	if !s.logger.Enabled(ctx, level) {
+		return
+	}
+	if len(s.name) > 0 {
+		keyvals = append([]interface{}{logger.ComponentKey, s.name}, keyvals...)
+	}
+	s.logger.Log(ctx, level, msg, keyvals...)
+}
+
+func (s *slogLogger) Debug(msg string, keyvals ...interface{}) {
+	s.log(slog.LevelDebug, msg, keyvals)
+}
+
+func (s *slogLogger) Info(msg string, keyvals ...interface{}) {
+	s.log(slog.LevelInfo, msg, keyvals)
+}
+
+func (s *slogLogger) Warn(msg string, keyvals ...interface{}) {
+	s.log(slog.LevelWarn, msg, keyvals)
+}
+
+func (s *slogLogger) Error(msg string, keyvals ...interface{}) {
+	s.log(slog.LevelError, msg, keyvals)
+}
+
+func (s *slogLogger) With(keyvals ...interface{}) logger.Logger {
+	return &slogLogger{logger: s.logger.With(keyvals...), name: s.name}
+}
+
+func (s *slogLogger) Named(name string) logger.Logger {
+	return &slogLogger{logger: s.logger, name: logger.JoinName(s.name, name)}
+}
@@ -0,0 +1,32 @@
+//go:build go1.21
+// +build go1.21
+
+package slog
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func Test_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	l := NewLogger(slog.New(handler)).Named("http").With("target", "users")
+	l.Debug("dropped")
+	l.Named("balancer").Warn("pick failed", "attempt", 2)
+
+	expect := `level=WARN msg="pick failed" target=users component=http.balancer attempt=2`
+	if get := strings.TrimSpace(buf.String()); get != expect {
+		t.Fatalf("expect:%v,but get:%v", expect, get)
+	}
+}
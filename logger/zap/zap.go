@@ -0,0 +1,50 @@
+// Package zap implements logger.Logger with a zap.Logger
+package zap
+
+import (
+	"github.com/classtorch/prpc/logger"
+	"go.uber.org/zap"
+)
+
+// zapLogger a logger.Logger writing to a zap.Logger
+type zapLogger struct {
+	logger *zap.Logger
+}
+
+// NewLogger return a logger.Logger writing to l, the names of the components are the names of the zap.Logger
+func NewLogger(l *zap.Logger) logger.Logger {
+	return &zapLogger{logger: l}
+}
+
+func fields(keyvals []interface{}) []zap.Field {
+	kvs := logger.KeyValues(keyvals...)
+	fs := make([]zap.Field, len(kvs))
+	for i, kv := range kvs {
+		fs[i] = zap.Any(kv.Key, kv.Value)
+	}
+	return fs
+}
+
+func (z *zapLogger) Debug(msg string, keyvals ...interface{}) {
+	z.logger.Debug(msg, fields(keyvals)...)
+}
+
+func (z *zapLogger) Info(msg string, keyvals ...interface{}) {
+	z.logger.Info(msg, fields(keyvals)...)
+}
+
+func (z *zapLogger) Warn(msg string, keyvals ...interface{}) {
+	z.logger.Warn(msg, fields(keyvals)...)
+}
+
+func (z *zapLogger) Error(msg string, keyvals ...interface{}) {
+	z.logger.Error(msg, fields(keyvals)...)
+}
+
+func (z *zapLogger) With(keyvals ...interface{}) logger.Logger {
+	return &zapLogger{logger: z.logger.With(fields(keyvals)...)}
+}
+
+func (z *zapLogger) Named(name string) logger.Logger {
+	return &zapLogger{logger: z.logger.Named(name)}
+}
@@ -0,0 +1,28 @@
+package zap
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"testing"
+)
+
+func Test_Logger(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	l := NewLogger(zap.New(core)).Named("http").With("target", "users")
+	l.Debug("dropped")
+	l.Named("balancer").Warn("pick failed", "attempt", 2)
+
+	entries := logs.AllUntimed()
+	if len(entries) != 1 {
+		t.Fatalf("expect:1 entry,but get:%v", entries)
+	}
+	entry := entries[0]
+	if entry.Level != zapcore.WarnLevel || entry.LoggerName != "http.balancer" || entry.Message != "pick failed" {
+		t.Fatalf("expect:warn http.balancer pick failed,but get:%v %v %v", entry.Level, entry.LoggerName, entry.Message)
+	}
+	fields := entry.ContextMap()
+	if len(fields) != 2 || fields["target"] != "users" || fields["attempt"] != int64(2) {
+		t.Fatalf("expect:target=users attempt=2,but get:%v", fields)
+	}
+}
@@ -0,0 +1,119 @@
+// Package metadata is the metadata of the calls of both transports. Outgoing metadata attached to
+// the context is sent as grpc metadata by grpc calls and as http headers by http calls, so the
+// generated clients set it the same way whatever the transport.
+package metadata
+
+import (
+	"context"
+	"encoding/base64"
+	"google.golang.org/grpc/metadata"
+	"net/http"
+	"strings"
+)
+
+// binSuffix keys with this suffix have binary values, base64 encoded on the wire
+const binSuffix = "-bin"
+
+// MD is a mapping from lowercase metadata keys to values, it is the grpc metadata type
+type MD = metadata.MD
+
+// reservedKeys keys managed by the transports, they are not sent as http headers
+var reservedKeys = map[string]bool{
+	"host":              true,
+	"content-type":      true,
+	"content-length":    true,
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"te":                true,
+	"prpc-timeout":      true,
+}
+
+// New create a MD from a given key-value map
+func New(m map[string]string) MD {
+	return metadata.New(m)
+}
+
+// Pairs return a MD formed by the mapping of key, value ..., keys are lowercased
+func Pairs(kv ...string) MD {
+	return metadata.Pairs(kv...)
+}
+
+// Join join any number of mds into a single MD
+func Join(mds ...MD) MD {
+	return metadata.Join(mds...)
+}
+
+// NewOutgoingContext create a new context with outgoing md attached
+func NewOutgoingContext(ctx context.Context, md MD) context.Context {
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// AppendToOutgoingContext return a new context with the provided kv merged with any existing metadata in the context
+func AppendToOutgoingContext(ctx context.Context, kv ...string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// FromOutgoingContext return the outgoing metadata in ctx if it exists
+func FromOutgoingContext(ctx context.Context) (MD, bool) {
+	return metadata.FromOutgoingContext(ctx)
+}
+
+// FromIncomingContext return the incoming metadata in ctx if it exists
+func FromIncomingContext(ctx context.Context) (MD, bool) {
+	return metadata.FromIncomingContext(ctx)
+}
+
+// IsReserved report whether key is managed by the transports: pseudo-headers, "grpc-" keys,
+// hop-by-hop headers, Host, Content-Type, Content-Length and the prpc timeout header.
+// Reserved keys are not sent as http headers.
+func IsReserved(key string) bool {
+	key = strings.ToLower(key)
+	return strings.HasPrefix(key, ":") || strings.HasPrefix(key, "grpc-") || reservedKeys[key]
+}
+
+// ToHTTPHeader add the non reserved keys of md to header, every value is added and the values of
+// "-bin" keys are base64 encoded
+func ToHTTPHeader(md MD, header http.Header) {
+	for k, vs := range md {
+		if IsReserved(k) {
+			continue
+		}
+		binary := strings.HasSuffix(strings.ToLower(k), binSuffix)
+		for _, v := range vs {
+			if binary {
+				v = base64.RawStdEncoding.EncodeToString([]byte(v))
+			}
+			header.Add(k, v)
+		}
+	}
+}
+
+// FromHTTPHeader convert header to a MD, the values of "-bin" keys are base64 decoded,
+// values that can not be decoded are kept as is
+func FromHTTPHeader(header http.Header) MD {
+	md := make(MD, len(header))
+	for k, vs := range header {
+		k = strings.ToLower(k)
+		binary := strings.HasSuffix(k, binSuffix)
+		for _, v := range vs {
+			if binary {
+				if decoded, err := decodeBinHeader(v); err == nil {
+					v = string(decoded)
+				}
+			}
+			md[k] = append(md[k], v)
+		}
+	}
+	return md
+}
+
+// decodeBinHeader decode a base64 value with or without padding
+func decodeBinHeader(v string) ([]byte, error) {
+	if len(v)%4 == 0 {
+		return base64.StdEncoding.DecodeString(v)
+	}
+	return base64.RawStdEncoding.DecodeString(v)
+}
@@ -0,0 +1,45 @@
+package metadata
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func Test_HTTPHeader(t *testing.T) {
+	md := Pairs(
+		"x-user", "1",
+		"accept", "text/plain",
+		"accept", "application/json",
+		"trace-bin", "\x00\x01binary",
+		"grpc-timeout", "1S",
+		":authority", "example.com",
+		"content-type", "application/grpc",
+		"connection", "close",
+		"prpc-timeout", "1S",
+	)
+	header := http.Header{}
+	ToHTTPHeader(md, header)
+	expect := http.Header{
+		"X-User":    {"1"},
+		"Accept":    {"text/plain", "application/json"},
+		"Trace-Bin": {"AAFiaW5hcnk"},
+	}
+	if !reflect.DeepEqual(header, expect) {
+		t.Fatalf("expect:%v,but get:%v", expect, header)
+	}
+
+	header.Add("Padded-Bin", "AAFiaW5hcnk=")
+	header.Add("Broken-Bin", "!")
+	got := FromHTTPHeader(header)
+	expectMD := MD{
+		"x-user":     {"1"},
+		"accept":     {"text/plain", "application/json"},
+		"trace-bin":  {"\x00\x01binary"},
+		"padded-bin": {"\x00\x01binary"},
+		"broken-bin": {"!"},
+	}
+	if !reflect.DeepEqual(got, expectMD) {
+		t.Fatalf("expect:%v,but get:%v", expectMD, got)
+	}
+}
@@ -0,0 +1,71 @@
+package prpc
+
+import (
+	"context"
+	"github.com/classtorch/prpc/metrics"
+	"github.com/classtorch/prpc/wrapper"
+	"google.golang.org/grpc/status"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// WithMetricsRecorder record the calls, the picks and the ready addresses of the http and grpc ClientConn to recorder,
+// the calls are recorded by an interceptor added like WithUnaryInterceptor. The resolver updates are recorded by the
+// resolvers given the recorder, e.g. consul.WithMetricsRecorder, not by this option
+func WithMetricsRecorder(recorder metrics.MetricsRecorder) Option {
+	return func(o *connectOption) {
+		o.metrics = recorder
+		o.unaryInterceptors = append(o.unaryInterceptors, MetricsInterceptor(recorder))
+	}
+}
+
+// MetricsInterceptor return an interceptor recording the calls, their retries and their duration to recorder,
+// the duration of the http calls includes the pick done before the interceptors run
+func MetricsInterceptor(recorder metrics.MetricsRecorder) UnaryInterceptor {
+	return func(ctx context.Context, info *CallInfo, req interface{}, reply interface{}, invoker UnaryInvoker) error {
+		start := time.Now()
+		if record := wrapper.ContextPickRecord(ctx); record != nil {
+			start = record.Start
+		}
+		call := metricsCall(info)
+		recorder.CallStarted(call)
+		var attempts int32
+		ctx = WithAttemptTrace(ctx, info, func(hedged bool) {
+			if atomic.AddInt32(&attempts, 1) > 1 {
+				recorder.CallRetried(call, hedged)
+			}
+		})
+		err := invoker(ctx, info, req, reply)
+		recorder.CallFinished(call, metricsStatus(info, err), info.Peer, time.Since(start))
+		return err
+	}
+}
+
+// metricsCall return the labels of the call
+func metricsCall(info *CallInfo) metrics.Call {
+	call := metrics.Call{Transport: string(info.Transport)}
+	if info.Transport == TransportHttp {
+		call.Service, call.Method = info.Path, info.Verb
+		return call
+	}
+	// /package.Service/Method
+	if parts := strings.SplitN(strings.TrimPrefix(info.FullMethod, "/"), "/", 2); len(parts) == 2 {
+		call.Service, call.Method = parts[0], parts[1]
+	} else {
+		call.Method = info.FullMethod
+	}
+	return call
+}
+
+// metricsStatus return the grpc code or the http status code of the call
+func metricsStatus(info *CallInfo, err error) string {
+	if info.Transport == TransportGrpc {
+		return status.Code(err).String()
+	}
+	if info.StatusCode > 0 {
+		return strconv.Itoa(info.StatusCode)
+	}
+	return metrics.StatusError
+}
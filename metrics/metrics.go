@@ -0,0 +1,53 @@
+// Package metrics defines the MetricsRecorder recording the metrics of the calls, the picks and the resolvers,
+// see the prometheus and otel sub packages for the implementations.
+package metrics
+
+import (
+	"time"
+)
+
+const (
+	// StatusError the status of the http calls failing without a response
+	StatusError = "error"
+	// RetryKind the kind of the attempts sent once the previous ones of the call are done
+	RetryKind = "retry"
+	// HedgeKind the kind of the attempts sent while another attempt of the call is in flight
+	HedgeKind = "hedge"
+)
+
+// Call the labels of a call, the grpc calls are labeled with the service and the method of their full method,
+// the http calls with their api and their http method
+type Call struct {
+	Transport string
+	Service   string
+	Method    string
+}
+
+// MetricsRecorder records the metrics of the calls, the picks and the resolvers, the implementations must be
+// safe for concurrent use. The target of the picks and the resolvers is the endpoint of the target of the ClientConn,
+// e.g. the service name for consul://127.0.0.1:8500/service.
+type MetricsRecorder interface {
+	// CallStarted is called when a call starts
+	CallStarted(call Call)
+	// CallFinished is called once a call returns, status is the grpc code or the http status code of the call
+	CallFinished(call Call, status string, peer string, duration time.Duration)
+	// CallRetried is called each time a call is sent again, i.e. for each attempt after the first one, hedged is
+	// true for the attempts sent while another attempt of the call is in flight
+	CallRetried(call Call, hedged bool)
+	// PickWait is called once an address is picked for a call, wait is the time spent in the pick
+	PickWait(target string, wait time.Duration, err error)
+	// ReadyAddresses is called when the number of addresses the calls can be sent to changes
+	ReadyAddresses(target string, count int)
+	// ResolverUpdate is called when the resolver updates the addresses of target or reports err
+	ResolverUpdate(target string, err error)
+}
+
+// NopRecorder a MetricsRecorder recording nothing
+type NopRecorder struct{}
+
+func (NopRecorder) CallStarted(Call)                                 {}
+func (NopRecorder) CallFinished(Call, string, string, time.Duration) {}
+func (NopRecorder) CallRetried(Call, bool)                           {}
+func (NopRecorder) PickWait(string, time.Duration, error)            {}
+func (NopRecorder) ReadyAddresses(string, int)                       {}
+func (NopRecorder) ResolverUpdate(string, error)                     {}
@@ -0,0 +1,164 @@
+// Package otel implements metrics.MetricsRecorder with OpenTelemetry instruments
+package otel
+
+import (
+	"context"
+	"github.com/classtorch/prpc/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/asyncint64"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/metric/unit"
+	"sync"
+	"time"
+)
+
+const (
+	// instrumentationName the name of the meter
+	instrumentationName = "github.com/classtorch/prpc/metrics/otel"
+
+	TransportKey = attribute.Key("transport")
+	ServiceKey   = attribute.Key("service")
+	MethodKey    = attribute.Key("method")
+	StatusKey    = attribute.Key("status")
+	PeerKey      = attribute.Key("peer")
+	KindKey      = attribute.Key("kind")
+	TargetKey    = attribute.Key("target")
+)
+
+// recorderOptions Recorder options
+type recorderOptions struct {
+	meterProvider metric.MeterProvider
+}
+
+type RecorderOption func(*recorderOptions)
+
+// WithMeterProvider create the instruments with provider instead of the global one
+func WithMeterProvider(provider metric.MeterProvider) RecorderOption {
+	return func(o *recorderOptions) {
+		o.meterProvider = provider
+	}
+}
+
+// Recorder a metrics.MetricsRecorder recording to OpenTelemetry instruments
+type Recorder struct {
+	calls           syncint64.Counter
+	callDuration    syncfloat64.Histogram
+	inFlight        syncint64.UpDownCounter
+	retries         syncint64.Counter
+	pickWait        syncfloat64.Histogram
+	pickErrors      syncint64.Counter
+	readyAddresses  asyncint64.Gauge
+	resolverUpdates syncint64.Counter
+	resolverErrors  syncint64.Counter
+
+	mu    sync.Mutex
+	ready map[string]int64 // ready addresses by target, observed by the readyAddresses callback
+}
+
+// NewRecorder return a Recorder, an error is returned if the instruments can not be created
+func NewRecorder(opts ...RecorderOption) (*Recorder, error) {
+	o := recorderOptions{meterProvider: global.MeterProvider()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	meter := o.meterProvider.Meter(instrumentationName)
+	r := &Recorder{ready: make(map[string]int64)}
+	var err error
+	if r.calls, err = meter.SyncInt64().Counter("prpc.client.calls",
+		instrument.WithDescription("Number of calls finished by the client.")); err != nil {
+		return nil, err
+	}
+	if r.callDuration, err = meter.SyncFloat64().Histogram("prpc.client.duration", instrument.WithUnit("s"),
+		instrument.WithDescription("Duration of the calls, including the pick of the address.")); err != nil {
+		return nil, err
+	}
+	if r.inFlight, err = meter.SyncInt64().UpDownCounter("prpc.client.in_flight",
+		instrument.WithDescription("Number of calls started and not finished yet.")); err != nil {
+		return nil, err
+	}
+	if r.retries, err = meter.SyncInt64().Counter("prpc.client.retries",
+		instrument.WithDescription("Number of requests sent again by the calls, by kind: retry or hedge.")); err != nil {
+		return nil, err
+	}
+	if r.pickWait, err = meter.SyncFloat64().Histogram("prpc.balancer.pick_wait", instrument.WithUnit("s"),
+		instrument.WithDescription("Time spent picking an address for the calls.")); err != nil {
+		return nil, err
+	}
+	if r.pickErrors, err = meter.SyncInt64().Counter("prpc.balancer.pick_errors",
+		instrument.WithDescription("Number of picks failing to find an address.")); err != nil {
+		return nil, err
+	}
+	if r.readyAddresses, err = meter.AsyncInt64().Gauge("prpc.balancer.ready_addresses", instrument.WithUnit(unit.Dimensionless),
+		instrument.WithDescription("Number of addresses the calls can be sent to.")); err != nil {
+		return nil, err
+	}
+	if r.resolverUpdates, err = meter.SyncInt64().Counter("prpc.resolver.updates",
+		instrument.WithDescription("Number of address updates of the resolvers.")); err != nil {
+		return nil, err
+	}
+	if r.resolverErrors, err = meter.SyncInt64().Counter("prpc.resolver.errors",
+		instrument.WithDescription("Number of errors reported by the resolvers.")); err != nil {
+		return nil, err
+	}
+	if err = meter.RegisterCallback([]instrument.Asynchronous{r.readyAddresses}, r.observeReadyAddresses); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Recorder) observeReadyAddresses(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for target, count := range r.ready {
+		r.readyAddresses.Observe(ctx, count, TargetKey.String(target))
+	}
+}
+
+func callAttributes(call metrics.Call) []attribute.KeyValue {
+	return []attribute.KeyValue{TransportKey.String(call.Transport), ServiceKey.String(call.Service), MethodKey.String(call.Method)}
+}
+
+func (r *Recorder) CallStarted(call metrics.Call) {
+	r.inFlight.Add(context.Background(), 1, callAttributes(call)...)
+}
+
+func (r *Recorder) CallFinished(call metrics.Call, status string, peer string, duration time.Duration) {
+	attrs := callAttributes(call)
+	r.inFlight.Add(context.Background(), -1, attrs...)
+	attrs = append(attrs, StatusKey.String(status), PeerKey.String(peer))
+	r.calls.Add(context.Background(), 1, attrs...)
+	r.callDuration.Record(context.Background(), duration.Seconds(), attrs...)
+}
+
+func (r *Recorder) CallRetried(call metrics.Call, hedged bool) {
+	kind := metrics.RetryKind
+	if hedged {
+		kind = metrics.HedgeKind
+	}
+	r.retries.Add(context.Background(), 1, append(callAttributes(call), KindKey.String(kind))...)
+}
+
+func (r *Recorder) PickWait(target string, wait time.Duration, err error) {
+	r.pickWait.Record(context.Background(), wait.Seconds(), TargetKey.String(target))
+	if err != nil {
+		r.pickErrors.Add(context.Background(), 1, TargetKey.String(target))
+	}
+}
+
+func (r *Recorder) ReadyAddresses(target string, count int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ready[target] = int64(count)
+}
+
+func (r *Recorder) ResolverUpdate(target string, err error) {
+	if err != nil {
+		r.resolverErrors.Add(context.Background(), 1, TargetKey.String(target))
+		return
+	}
+	r.resolverUpdates.Add(context.Background(), 1, TargetKey.String(target))
+}
@@ -0,0 +1,76 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"github.com/classtorch/prpc/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_Recorder(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	recorder, err := NewRecorder(WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := metrics.Call{Transport: "http", Service: "/users/{uid}", Method: "GET"}
+	recorder.CallStarted(call)
+	recorder.CallStarted(call)
+	recorder.CallRetried(call, false)
+	recorder.CallRetried(call, true)
+	recorder.CallFinished(call, "200", "127.0.0.1:8000", 10*time.Millisecond)
+	recorder.PickWait("user", 5*time.Millisecond, nil)
+	recorder.PickWait("user", time.Second, errors.New("no address"))
+	recorder.ReadyAddresses("user", 3)
+	recorder.ReadyAddresses("user", 2)
+	recorder.ResolverUpdate("user", nil)
+	recorder.ResolverUpdate("user", errors.New("consul down"))
+
+	data, err := reader.Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sum the values of the sums and the gauges and the counts of the histograms, the data types are generic
+	values := make(map[string]float64)
+	kinds := make(map[string]int64)
+	for _, scope := range data.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			points := reflect.ValueOf(m.Data).FieldByName("DataPoints")
+			for i := 0; i < points.Len(); i++ {
+				point := points.Index(i)
+				attrs := point.FieldByName("Attributes").Interface().(attribute.Set)
+				if kind, ok := attrs.Value(KindKey); ok {
+					kinds[kind.AsString()] += point.FieldByName("Value").Int()
+				}
+				if value := point.FieldByName("Value"); value.IsValid() {
+					values[m.Name] += float64(value.Int())
+				} else {
+					values[m.Name] += float64(point.FieldByName("Count").Uint())
+				}
+			}
+		}
+	}
+	expect := map[string]float64{
+		"prpc.client.calls":             1,
+		"prpc.client.duration":          1,
+		"prpc.client.in_flight":         1,
+		"prpc.client.retries":           2,
+		"prpc.balancer.pick_wait":       2,
+		"prpc.balancer.pick_errors":     1,
+		"prpc.balancer.ready_addresses": 2,
+		"prpc.resolver.updates":         1,
+		"prpc.resolver.errors":          1,
+	}
+	for name, v := range expect {
+		if values[name] != v {
+			t.Fatalf("expect %s:%v,but get:%v", name, v, values[name])
+		}
+	}
+	if kinds[metrics.RetryKind] != 1 || kinds[metrics.HedgeKind] != 1 {
+		t.Fatalf("expect:1 retry and 1 hedge,but get:%v", kinds)
+	}
+}
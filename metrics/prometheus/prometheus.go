@@ -0,0 +1,174 @@
+// Package prometheus implements metrics.MetricsRecorder with Prometheus collectors
+package prometheus
+
+import (
+	"github.com/classtorch/prpc/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+const (
+	defaultNamespace = "prpc"
+)
+
+var (
+	callLabels   = []string{"transport", "service", "method"}
+	resultLabels = []string{"transport", "service", "method", "status"}
+	retryLabels  = []string{"transport", "service", "method", "kind"}
+)
+
+// recorderOptions Recorder options
+type recorderOptions struct {
+	namespace string
+	buckets   []float64
+	peerLabel bool
+}
+
+type RecorderOption func(*recorderOptions)
+
+// WithNamespace prefix the names of the metrics with namespace instead of prpc
+func WithNamespace(namespace string) RecorderOption {
+	return func(o *recorderOptions) {
+		o.namespace = namespace
+	}
+}
+
+// WithBuckets use buckets for the call duration and the pick wait histograms, in seconds
+func WithBuckets(buckets []float64) RecorderOption {
+	return func(o *recorderOptions) {
+		o.buckets = buckets
+	}
+}
+
+// WithPeerLabel label the calls and their duration with the address of their peer, the number of series
+// grows with the number of addresses of the targets, which is unbounded when the addresses change often
+func WithPeerLabel() RecorderOption {
+	return func(o *recorderOptions) {
+		o.peerLabel = true
+	}
+}
+
+// Recorder a metrics.MetricsRecorder which is a prometheus.Collector, register it to expose the metrics
+type Recorder struct {
+	calls           *prometheus.CounterVec
+	callDuration    *prometheus.HistogramVec
+	inFlight        *prometheus.GaugeVec
+	retries         *prometheus.CounterVec
+	pickWait        *prometheus.HistogramVec
+	pickErrors      *prometheus.CounterVec
+	readyAddresses  *prometheus.GaugeVec
+	resolverUpdates *prometheus.CounterVec
+	resolverErrors  *prometheus.CounterVec
+	peerLabel       bool
+}
+
+// NewRecorder return a Recorder, it must be registered, e.g. prometheus.MustRegister(recorder)
+func NewRecorder(opts ...RecorderOption) *Recorder {
+	o := recorderOptions{namespace: defaultNamespace, buckets: prometheus.DefBuckets}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	labels := resultLabels
+	if o.peerLabel {
+		labels = append(append([]string(nil), resultLabels...), "peer")
+	}
+	return &Recorder{
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace, Subsystem: "client", Name: "calls_total",
+			Help: "Total number of calls finished by the client.",
+		}, labels),
+		callDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.namespace, Subsystem: "client", Name: "call_duration_seconds",
+			Help: "Duration of the calls, including the pick of the address.", Buckets: o.buckets,
+		}, labels),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: o.namespace, Subsystem: "client", Name: "calls_in_flight",
+			Help: "Number of calls started and not finished yet.",
+		}, callLabels),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace, Subsystem: "client", Name: "retries_total",
+			Help: "Total number of requests sent again by the calls, by kind: retry or hedge.",
+		}, retryLabels),
+		pickWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.namespace, Subsystem: "balancer", Name: "pick_wait_seconds",
+			Help: "Time spent picking an address for the calls.", Buckets: o.buckets,
+		}, []string{"target"}),
+		pickErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace, Subsystem: "balancer", Name: "pick_errors_total",
+			Help: "Total number of picks failing to find an address.",
+		}, []string{"target"}),
+		readyAddresses: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: o.namespace, Subsystem: "balancer", Name: "ready_addresses",
+			Help: "Number of addresses the calls can be sent to.",
+		}, []string{"target"}),
+		resolverUpdates: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace, Subsystem: "resolver", Name: "updates_total",
+			Help: "Total number of address updates of the resolvers.",
+		}, []string{"target"}),
+		resolverErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace, Subsystem: "resolver", Name: "errors_total",
+			Help: "Total number of errors reported by the resolvers.",
+		}, []string{"target"}),
+		peerLabel: o.peerLabel,
+	}
+}
+
+func (r *Recorder) collectors() []prometheus.Collector {
+	return []prometheus.Collector{r.calls, r.callDuration, r.inFlight, r.retries, r.pickWait, r.pickErrors,
+		r.readyAddresses, r.resolverUpdates, r.resolverErrors}
+}
+
+// Describe implements prometheus.Collector
+func (r *Recorder) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range r.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector
+func (r *Recorder) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range r.collectors() {
+		c.Collect(ch)
+	}
+}
+
+func (r *Recorder) CallStarted(call metrics.Call) {
+	r.inFlight.WithLabelValues(call.Transport, call.Service, call.Method).Inc()
+}
+
+func (r *Recorder) CallFinished(call metrics.Call, status string, peer string, duration time.Duration) {
+	r.inFlight.WithLabelValues(call.Transport, call.Service, call.Method).Dec()
+	values := []string{call.Transport, call.Service, call.Method, status}
+	if r.peerLabel {
+		values = append(values, peer)
+	}
+	r.calls.WithLabelValues(values...).Inc()
+	r.callDuration.WithLabelValues(values...).Observe(duration.Seconds())
+}
+
+func (r *Recorder) CallRetried(call metrics.Call, hedged bool) {
+	kind := metrics.RetryKind
+	if hedged {
+		kind = metrics.HedgeKind
+	}
+	r.retries.WithLabelValues(call.Transport, call.Service, call.Method, kind).Inc()
+}
+
+func (r *Recorder) PickWait(target string, wait time.Duration, err error) {
+	r.pickWait.WithLabelValues(target).Observe(wait.Seconds())
+	if err != nil {
+		r.pickErrors.WithLabelValues(target).Inc()
+	}
+}
+
+func (r *Recorder) ReadyAddresses(target string, count int) {
+	r.readyAddresses.WithLabelValues(target).Set(float64(count))
+}
+
+func (r *Recorder) ResolverUpdate(target string, err error) {
+	if err != nil {
+		r.resolverErrors.WithLabelValues(target).Inc()
+		return
+	}
+	r.resolverUpdates.WithLabelValues(target).Inc()
+}
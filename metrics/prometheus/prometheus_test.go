@@ -0,0 +1,84 @@
+package prometheus
+
+import (
+	"errors"
+	"github.com/classtorch/prpc/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Recorder(t *testing.T) {
+	recorder := NewRecorder(WithNamespace("test"))
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(recorder)
+
+	call := metrics.Call{Transport: "grpc", Service: "user.User", Method: "Get"}
+	recorder.CallStarted(call)
+	recorder.CallStarted(call)
+	recorder.CallRetried(call, false)
+	recorder.CallRetried(call, true)
+	recorder.CallFinished(call, "OK", "127.0.0.1:8000", 10*time.Millisecond)
+	recorder.PickWait("user", 5*time.Millisecond, nil)
+	recorder.PickWait("user", time.Second, errors.New("no address"))
+	recorder.ReadyAddresses("user", 3)
+	recorder.ResolverUpdate("user", nil)
+	recorder.ResolverUpdate("user", errors.New("consul down"))
+
+	expect := `
+# HELP test_client_calls_in_flight Number of calls started and not finished yet.
+# TYPE test_client_calls_in_flight gauge
+test_client_calls_in_flight{method="Get",service="user.User",transport="grpc"} 1
+# HELP test_client_calls_total Total number of calls finished by the client.
+# TYPE test_client_calls_total counter
+test_client_calls_total{method="Get",service="user.User",status="OK",transport="grpc"} 1
+# HELP test_client_retries_total Total number of requests sent again by the calls, by kind: retry or hedge.
+# TYPE test_client_retries_total counter
+test_client_retries_total{kind="hedge",method="Get",service="user.User",transport="grpc"} 1
+test_client_retries_total{kind="retry",method="Get",service="user.User",transport="grpc"} 1
+# HELP test_balancer_pick_errors_total Total number of picks failing to find an address.
+# TYPE test_balancer_pick_errors_total counter
+test_balancer_pick_errors_total{target="user"} 1
+# HELP test_balancer_ready_addresses Number of addresses the calls can be sent to.
+# TYPE test_balancer_ready_addresses gauge
+test_balancer_ready_addresses{target="user"} 3
+# HELP test_resolver_errors_total Total number of errors reported by the resolvers.
+# TYPE test_resolver_errors_total counter
+test_resolver_errors_total{target="user"} 1
+# HELP test_resolver_updates_total Total number of address updates of the resolvers.
+# TYPE test_resolver_updates_total counter
+test_resolver_updates_total{target="user"} 1
+`
+	names := []string{"test_client_calls_in_flight", "test_client_calls_total", "test_client_retries_total", "test_balancer_pick_errors_total",
+		"test_balancer_ready_addresses", "test_resolver_errors_total", "test_resolver_updates_total"}
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expect), names...); err != nil {
+		t.Fatal(err)
+	}
+	if count := testutil.CollectAndCount(recorder, "test_client_call_duration_seconds", "test_balancer_pick_wait_seconds"); count != 2 {
+		t.Fatalf("expect 2 histograms,but get:%v", count)
+	}
+}
+
+func Test_RecorderPeerLabel(t *testing.T) {
+	recorder := NewRecorder(WithNamespace("test"), WithPeerLabel())
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(recorder)
+
+	call := metrics.Call{Transport: "http", Service: "/users/{uid}", Method: "GET"}
+	recorder.CallStarted(call)
+	recorder.CallFinished(call, "200", "127.0.0.1:8000", 10*time.Millisecond)
+
+	expect := `
+# HELP test_client_calls_total Total number of calls finished by the client.
+# TYPE test_client_calls_total counter
+test_client_calls_total{method="GET",peer="127.0.0.1:8000",service="/users/{uid}",status="200",transport="http"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expect), "test_client_calls_total"); err != nil {
+		t.Fatal(err)
+	}
+	if count := testutil.CollectAndCount(recorder, "test_client_call_duration_seconds"); count != 1 {
+		t.Fatalf("expect 1 histogram,but get:%v", count)
+	}
+}
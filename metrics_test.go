@@ -0,0 +1,168 @@
+package prpc
+
+import (
+	"context"
+	"fmt"
+	"github.com/classtorch/prpc/grpc"
+	"github.com/classtorch/prpc/http"
+	"github.com/classtorch/prpc/metrics"
+	"github.com/classtorch/prpc/resolver"
+	grpcRaw "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// staticResolverBuilder resolve addresses after delay
+type staticResolverBuilder struct {
+	scheme    string
+	addresses []string
+	delay     time.Duration
+}
+
+func (b staticResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn) (resolver.Resolver, error) {
+	state := resolver.State{}
+	for _, addr := range b.addresses {
+		state.Addresses = append(state.Addresses, resolver.Address{Addr: addr})
+	}
+	time.AfterFunc(b.delay, func() {
+		cc.UpdateState(state)
+	})
+	return staticResolver{}, nil
+}
+
+func (b staticResolverBuilder) Scheme() string {
+	return b.scheme
+}
+
+type staticResolver struct{}
+
+func (staticResolver) ResolveNow() {}
+
+func (staticResolver) Close() {}
+
+// recorder record the metrics as strings
+type recorder struct {
+	mu       sync.Mutex
+	calls    []string
+	pickWait time.Duration
+	ready    map[string]int
+}
+
+func (r *recorder) add(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) CallStarted(call metrics.Call) {
+	r.add("started %s %s %s", call.Transport, call.Service, call.Method)
+}
+
+func (r *recorder) CallFinished(call metrics.Call, status string, peer string, duration time.Duration) {
+	r.add("finished %s %s %s %s %s", call.Transport, call.Service, call.Method, status, peer)
+}
+
+func (r *recorder) CallRetried(call metrics.Call, hedged bool) {
+	r.add("retried %s %s %s %v", call.Transport, call.Service, call.Method, hedged)
+}
+
+func (r *recorder) PickWait(target string, wait time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if wait > r.pickWait {
+		r.pickWait = wait
+	}
+}
+
+func (r *recorder) ReadyAddresses(target string, count int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ready[target] = count
+}
+
+func (r *recorder) ResolverUpdate(target string, err error) {}
+
+func Test_MetricsRecorder(t *testing.T) {
+	attempts := 0
+	httpServer := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(nethttp.StatusServiceUnavailable)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer httpServer.Close()
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpcRaw.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, health.NewServer())
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	retry := func(ctx context.Context, req interface{}, reply interface{}, httpRequest *nethttp.Request, httpResponse *nethttp.Response, cc *http.ClientConn, invoker http.Invoker, option ...http.CallOption) error {
+		err := invoker(ctx, req, reply, httpRequest, httpResponse, cc, option...)
+		if httpResponse.StatusCode == nethttp.StatusServiceUnavailable {
+			err = invoker(ctx, req, reply, httpRequest, httpResponse, cc, option...)
+		}
+		return err
+	}
+	r := &recorder{ready: make(map[string]int)}
+	cc := NewClientConn(WithMetricsRecorder(r))
+	defer cc.Close()
+	httpAddr := httpServer.Listener.Addr().String()
+	err := cc.NewHttpClientConn(context.Background(), "static://127.0.0.1/users",
+		http.WithResolver(staticResolverBuilder{scheme: "static", addresses: []string{httpAddr}, delay: 50 * time.Millisecond}), http.WithInterceptor(retry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialer := func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	}
+	err = cc.NewGrpcClientConn(context.Background(), "static://127.0.0.1/health",
+		grpc.WithResolver(staticResolverBuilder{scheme: "static", addresses: []string{"bufnet"}}),
+		grpc.WithOptions(grpcRaw.WithContextDialer(dialer), grpcRaw.WithTransportCredentials(insecure.NewCredentials())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = cc.HttpInvoke(context.Background(), "GET", "/users/{uid}", nil, &struct{}{}, http.WithUrlParams(map[string]string{"uid": "1"})); err != nil {
+		t.Fatal(err)
+	}
+	if err = cc.GrpcInvoke(context.Background(), "/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	err = cc.GrpcInvoke(context.Background(), "/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{Service: "unknown"}, &healthpb.HealthCheckResponse{})
+	if err == nil {
+		t.Fatal("expect err:NotFound")
+	}
+
+	expect := []string{
+		"started http /users/{uid} GET",
+		"retried http /users/{uid} GET false",
+		"finished http /users/{uid} GET 200 " + httpAddr,
+		"started grpc grpc.health.v1.Health Check",
+		"finished grpc grpc.health.v1.Health Check OK bufconn",
+		"started grpc grpc.health.v1.Health Check",
+		"finished grpc grpc.health.v1.Health Check NotFound bufconn",
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if strings.Join(r.calls, "\n") != strings.Join(expect, "\n") {
+		t.Fatalf("expect:%v,but get:%v", expect, r.calls)
+	}
+	if r.ready["users"] != 1 || r.ready["health"] != 1 {
+		t.Fatalf("expect 1 ready address per target,but get:%v", r.ready)
+	}
+	// the http pick waited for the resolver
+	if r.pickWait < 25*time.Millisecond {
+		t.Fatalf("expect a pick wait of at least 25ms,but get:%v", r.pickWait)
+	}
+}
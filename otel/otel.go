@@ -0,0 +1,221 @@
+// Package otel provides interceptors tracing the http and grpc calls with OpenTelemetry.
+// The client spans carry the picked peer, the status and the retries of the call, the waits of the
+// pick, and the trace context is propagated in the http headers and the grpc metadata.
+package otel
+
+import (
+	"context"
+	"github.com/classtorch/prpc"
+	"github.com/classtorch/prpc/http"
+	"github.com/classtorch/prpc/metadata"
+	"github.com/classtorch/prpc/resolver"
+	"github.com/classtorch/prpc/wrapper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	grpcRaw "google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// instrumentationName the name of the tracer
+	instrumentationName = "github.com/classtorch/prpc/otel"
+
+	RetriesKey     = attribute.Key("prpc.retries")
+	PickAddressKey = attribute.Key("prpc.pick.address")
+	PickWaitKey    = attribute.Key("prpc.pick.wait_ms")
+
+	// PickEvent the event of the span once the address of the call is picked
+	PickEvent = "pick"
+	// PickerUpdatedEvent the event of the span when the address set changed while the pick was waiting
+	PickerUpdatedEvent = "picker updated"
+	// RetryEvent the event of the span when the call is sent again
+	RetryEvent = "retry"
+)
+
+// config of the interceptors
+type config struct {
+	tracerProvider trace.TracerProvider
+	propagator     propagation.TextMapPropagator
+}
+
+type Option func(*config)
+
+// WithTracerProvider create the spans with provider instead of the global one
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = provider
+	}
+}
+
+// WithPropagator propagate the context of the calls with propagator instead of W3C trace context and baggage
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagator = propagator
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		tracerProvider: otel.GetTracerProvider(),
+		propagator:     propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	}
+	for _, option := range opts {
+		option(c)
+	}
+	return c
+}
+
+// UnaryInterceptor return an interceptor creating a client span for the http and grpc calls,
+// install it with prpc.WithUnaryInterceptor
+func UnaryInterceptor(opts ...Option) prpc.UnaryInterceptor {
+	c := newConfig(opts)
+	tracer := c.tracerProvider.Tracer(instrumentationName)
+	return func(ctx context.Context, info *prpc.CallInfo, req interface{}, reply interface{}, invoker prpc.UnaryInvoker) error {
+		startOpts := []trace.SpanStartOption{trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(callAttributes(info)...)}
+		// the http address is picked before the interceptors run, the span starts with the pick
+		record := wrapper.ContextPickRecord(ctx)
+		if record != nil {
+			startOpts = append(startOpts, trace.WithTimestamp(record.Start))
+		}
+		ctx, span := tracer.Start(ctx, info.FullMethod, startOpts...)
+		defer span.End()
+		if record != nil {
+			for _, updated := range record.PickerUpdates {
+				span.AddEvent(PickerUpdatedEvent, trace.WithTimestamp(updated))
+			}
+			addPickEvent(span, record.Address, record.Wait, record.Start.Add(record.Wait))
+		}
+
+		md, _ := metadata.FromOutgoingContext(ctx)
+		md = md.Copy()
+		c.propagator.Inject(ctx, metadataCarrier(md))
+		ctx = metadata.NewOutgoingContext(ctx, md)
+
+		var attempts int32
+		ctx = prpc.WithAttemptTrace(ctx, info, func(bool) {
+			if atomic.AddInt32(&attempts, 1) > 1 {
+				span.AddEvent(RetryEvent)
+			}
+		})
+		if info.Transport == prpc.TransportGrpc {
+			ctx = wrapper.WithPickTrace(ctx, &wrapper.PickTrace{
+				PickerUpdated: func() {
+					span.AddEvent(PickerUpdatedEvent)
+				},
+				Picked: func(address resolver.Address, wait time.Duration, err error) {
+					if err == nil {
+						addPickEvent(span, address, wait, time.Now())
+					}
+				},
+			})
+		}
+
+		err := invoker(ctx, info, req, reply)
+
+		span.SetAttributes(peerAttributes(info.Peer)...)
+		if retries := atomic.LoadInt32(&attempts) - 1; retries > 0 {
+			span.SetAttributes(RetriesKey.Int(int(retries)))
+		}
+		switch info.Transport {
+		case prpc.TransportGrpc:
+			s, _ := status.FromError(err)
+			span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(s.Code())))
+			if err != nil {
+				span.SetStatus(codes.Error, s.Message())
+			}
+		case prpc.TransportHttp:
+			if info.StatusCode > 0 {
+				span.SetAttributes(semconv.HTTPStatusCodeKey.Int(info.StatusCode))
+				span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(info.StatusCode, trace.SpanKindClient))
+			}
+			if err != nil {
+				span.SetStatus(codes.Error, err.Error())
+			}
+		}
+		if err != nil {
+			span.RecordError(err)
+		}
+		return err
+	}
+}
+
+// HttpInterceptor return UnaryInterceptor as a http Interceptor
+func HttpInterceptor(opts ...Option) http.Interceptor {
+	return prpc.HttpInterceptor(UnaryInterceptor(opts...))
+}
+
+// GrpcUnaryInterceptor return UnaryInterceptor as a grpc UnaryClientInterceptor
+func GrpcUnaryInterceptor(opts ...Option) grpcRaw.UnaryClientInterceptor {
+	return prpc.GrpcUnaryInterceptor(UnaryInterceptor(opts...))
+}
+
+// callAttributes return the attributes of the call known before it is sent
+func callAttributes(info *prpc.CallInfo) []attribute.KeyValue {
+	if info.Transport == prpc.TransportHttp {
+		return []attribute.KeyValue{semconv.HTTPMethodKey.String(info.Verb), semconv.HTTPRouteKey.String(info.Path)}
+	}
+	attrs := []attribute.KeyValue{semconv.RPCSystemGRPC}
+	// /package.Service/Method
+	if parts := strings.SplitN(strings.TrimPrefix(info.FullMethod, "/"), "/", 2); len(parts) == 2 {
+		attrs = append(attrs, semconv.RPCServiceKey.String(parts[0]), semconv.RPCMethodKey.String(parts[1]))
+	}
+	return attrs
+}
+
+// peerAttributes return the attributes of the address of the server
+func peerAttributes(peer string) []attribute.KeyValue {
+	if len(peer) == 0 {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(peer)
+	if err != nil {
+		return []attribute.KeyValue{semconv.NetPeerNameKey.String(peer)}
+	}
+	attrs := []attribute.KeyValue{semconv.NetPeerNameKey.String(host)}
+	if p, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, semconv.NetPeerPortKey.Int(p))
+	}
+	return attrs
+}
+
+// addPickEvent add the pick event, no event is added if no address was picked, e.g. for direct connections
+func addPickEvent(span trace.Span, address resolver.Address, wait time.Duration, at time.Time) {
+	if len(address.Addr) == 0 {
+		return
+	}
+	span.AddEvent(PickEvent, trace.WithTimestamp(at), trace.WithAttributes(
+		PickAddressKey.String(address.Addr),
+		PickWaitKey.Float64(float64(wait)/float64(time.Millisecond)),
+	))
+}
+
+// metadataCarrier adapt metadata.MD to propagation.TextMapCarrier
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,215 @@
+package otel
+
+import (
+	"context"
+	"github.com/classtorch/prpc"
+	"github.com/classtorch/prpc/grpc"
+	"github.com/classtorch/prpc/http"
+	"github.com/classtorch/prpc/metadata"
+	"github.com/classtorch/prpc/resolver"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	grpcRaw "google.golang.org/grpc"
+	grpcCodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// delayResolverBuilder resolve the addresses after delay
+type delayResolverBuilder struct {
+	scheme    string
+	addresses []string
+	delay     time.Duration
+}
+
+func (b delayResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn) (resolver.Resolver, error) {
+	state := resolver.State{}
+	for _, addr := range b.addresses {
+		state.Addresses = append(state.Addresses, resolver.Address{Addr: addr})
+	}
+	if b.delay == 0 {
+		cc.UpdateState(state)
+	} else {
+		time.AfterFunc(b.delay, func() {
+			cc.UpdateState(state)
+		})
+	}
+	return nopResolver{}, nil
+}
+
+func (b delayResolverBuilder) Scheme() string {
+	return b.scheme
+}
+
+type nopResolver struct{}
+
+func (nopResolver) ResolveNow() {}
+
+func (nopResolver) Close() {}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func eventNames(span sdktrace.ReadOnlySpan) []string {
+	var names []string
+	for _, event := range span.Events() {
+		if len(names) == 0 || names[len(names)-1] != event.Name {
+			names = append(names, event.Name)
+		}
+	}
+	return names
+}
+
+func Test_HttpTracing(t *testing.T) {
+	var traceparents []string
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		if len(traceparents) == 1 {
+			w.WriteHeader(nethttp.StatusServiceUnavailable)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	retry := func(ctx context.Context, req interface{}, reply interface{}, httpRequest *nethttp.Request, httpResponse *nethttp.Response, cc *http.ClientConn, invoker http.Invoker, option ...http.CallOption) error {
+		err := invoker(ctx, req, reply, httpRequest, httpResponse, cc, option...)
+		if httpResponse.StatusCode == nethttp.StatusServiceUnavailable {
+			err = invoker(ctx, req, reply, httpRequest, httpResponse, cc, option...)
+		}
+		return err
+	}
+	cc := prpc.NewClientConn(prpc.WithUnaryInterceptor(UnaryInterceptor(WithTracerProvider(provider))))
+	defer cc.Close()
+	builder := delayResolverBuilder{scheme: "delay", addresses: []string{server.Listener.Addr().String()}, delay: 100 * time.Millisecond}
+	err := cc.NewHttpClientConn(context.Background(), "delay://127.0.0.1/users", http.WithResolver(builder), http.WithInterceptor(retry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cc.HttpInvoke(context.Background(), "POST", "/users/{uid}", nil, &struct{}{}, http.WithUrlParams(map[string]string{"uid": "1"})); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans().Snapshots()
+	if len(spans) != 1 {
+		t.Fatalf("expect 1 span,but get:%v", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "POST /users/{uid}" || span.Status().Code != codes.Unset {
+		t.Fatalf("unexpected span:%v, status:%v", span.Name(), span.Status())
+	}
+	attrs := attributes(span)
+	expect := map[attribute.Key]attribute.Value{
+		semconv.HTTPMethodKey:     attribute.StringValue("POST"),
+		semconv.HTTPRouteKey:      attribute.StringValue("/users/{uid}"),
+		semconv.HTTPStatusCodeKey: attribute.IntValue(200),
+		semconv.NetPeerNameKey:    attribute.StringValue(host),
+		RetriesKey:                attribute.IntValue(1),
+	}
+	for k, v := range expect {
+		if attrs[k] != v {
+			t.Fatalf("expect %s:%v,but get:%v", k, v.Emit(), attrs[k].Emit())
+		}
+	}
+	if strconv.Itoa(int(attrs[semconv.NetPeerPortKey].AsInt64())) != port {
+		t.Fatalf("expect port:%v,but get:%v", port, attrs[semconv.NetPeerPortKey].Emit())
+	}
+	// the pick waited for the resolver
+	if names := strings.Join(eventNames(span), ","); names != "picker updated,pick,retry" {
+		t.Fatalf("unexpected events:%v", names)
+	}
+	if wait := span.Events()[len(span.Events())-2].Attributes[1].Value.AsFloat64(); wait < 50 {
+		t.Fatalf("expect a pick wait of at least 50ms,but get:%vms", wait)
+	}
+	traceID := span.SpanContext().TraceID().String()
+	if len(traceparents) != 2 || !strings.Contains(traceparents[0], traceID) || traceparents[0] != traceparents[1] {
+		t.Fatalf("expect the trace id:%v to be propagated,but get:%v", traceID, traceparents)
+	}
+}
+
+func Test_GrpcTracing(t *testing.T) {
+	listener := bufconn.Listen(1024 * 1024)
+	var traceparents []string
+	serverInterceptor := func(ctx context.Context, req interface{}, info *grpcRaw.UnaryServerInfo, handler grpcRaw.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		traceparents = append(traceparents, md.Get("traceparent")...)
+		return handler(ctx, req)
+	}
+	server := grpcRaw.NewServer(grpcRaw.UnaryInterceptor(serverInterceptor))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	defer server.Stop()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	cc := prpc.NewClientConn(prpc.WithUnaryInterceptor(UnaryInterceptor(WithTracerProvider(provider))))
+	defer cc.Close()
+	dialer := func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	}
+	builder := delayResolverBuilder{scheme: "static", addresses: []string{"bufnet"}}
+	err := cc.NewGrpcClientConn(context.Background(), "static://127.0.0.1/health", grpc.WithResolver(builder),
+		grpc.WithOptions(grpcRaw.WithContextDialer(dialer), grpcRaw.WithTransportCredentials(insecure.NewCredentials())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cc.GrpcInvoke(context.Background(), "/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	err = cc.GrpcInvoke(context.Background(), "/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{Service: "unknown"}, &healthpb.HealthCheckResponse{})
+	if status.Code(err) != grpcCodes.NotFound {
+		t.Fatalf("expect code:%v,but get:%v", grpcCodes.NotFound, err)
+	}
+
+	spans := exporter.GetSpans().Snapshots()
+	if len(spans) != 2 {
+		t.Fatalf("expect 2 spans,but get:%v", len(spans))
+	}
+	for i, span := range spans {
+		attrs := attributes(span)
+		expect := map[attribute.Key]attribute.Value{
+			semconv.RPCSystemKey:         attribute.StringValue("grpc"),
+			semconv.RPCServiceKey:        attribute.StringValue("grpc.health.v1.Health"),
+			semconv.RPCMethodKey:         attribute.StringValue("Check"),
+			semconv.RPCGRPCStatusCodeKey: attribute.IntValue(int(grpcCodes.OK)),
+			semconv.NetPeerNameKey:       attribute.StringValue("bufconn"),
+		}
+		if i == 1 {
+			expect[semconv.RPCGRPCStatusCodeKey] = attribute.IntValue(int(grpcCodes.NotFound))
+		}
+		for k, v := range expect {
+			if attrs[k] != v {
+				t.Fatalf("span %d, expect %s:%v,but get:%v", i, k, v.Emit(), attrs[k].Emit())
+			}
+		}
+		if names := strings.Join(eventNames(span), ","); !strings.HasPrefix(names, "pick") {
+			t.Fatalf("span %d, unexpected events:%v", i, names)
+		}
+		if !strings.Contains(traceparents[i], span.SpanContext().TraceID().String()) {
+			t.Fatalf("span %d, expect the trace id:%v to be propagated,but get:%v", i, span.SpanContext().TraceID(), traceparents[i])
+		}
+	}
+	if spans[0].Status().Code != codes.Unset || spans[1].Status().Code != codes.Error {
+		t.Fatalf("unexpected status:%v,%v", spans[0].Status(), spans[1].Status())
+	}
+}
@@ -119,11 +119,47 @@ type Encoder interface {
 //
 // 	"user[name]=acme&user[addr][postcode]=1234&user[addr][city]=SFO"
 //
+// Elements of slices of structs are encoded as nested structs scoped by their
+// index, e.g. "items[0][id]=1&items[1][id]=2".
+//
 // All other values are encoded using their default string representation.
 //
 // Multiple fields that encode to the same URL parameter name will be included
 // as multiple URL values of the same name.
 func Values(v interface{}, tagName string) (url.Values, error) {
+	return ValuesWithStyle(v, tagName, KeyStyleDefault)
+}
+
+// KeyStyle is the naming of the keys of slice elements and nested struct fields.
+// Slice fields whose tag specifies a delimiter, "brackets" or "numbered" keep the tag naming.
+type KeyStyle int
+
+const (
+	// KeyStyleDefault encodes slices as repeated keys and nested fields as
+	// "user[name]", e.g. "a=1&a=2&user[name]=acme"
+	KeyStyleDefault KeyStyle = iota
+	// KeyStyleBrackets appends "[]" to the keys of slices, e.g. "a[]=1&a[]=2&user[name]=acme"
+	KeyStyleBrackets
+	// KeyStyleIndexed appends the index to the keys of slices, e.g. "a[0]=1&a[1]=2&user[name]=acme"
+	KeyStyleIndexed
+	// KeyStyleDotted joins nested fields with dots, e.g. "a=1&a=2&user.name=acme&items[0].id=1"
+	KeyStyleDotted
+)
+
+// nestedKey returns the key of the field name of a struct encoded as scope
+func (s KeyStyle) nestedKey(scope, name string) string {
+	if s == KeyStyleDotted {
+		return scope + "." + name
+	}
+	return scope + "[" + name + "]"
+}
+
+// ValuesWithStyle returns the url.Values encoding of v like Values, naming the keys of
+// slice elements and nested struct fields with style.
+//
+// Elements of slices of structs are always encoded with their index, e.g.
+// "items[0][id]=1", or "items[0].id=1" with KeyStyleDotted.
+func ValuesWithStyle(v interface{}, tagName string, style KeyStyle) (url.Values, error) {
 	values := make(url.Values)
 	val := reflect.ValueOf(v)
 	for val.Kind() == reflect.Ptr {
@@ -141,14 +177,14 @@ func Values(v interface{}, tagName string) (url.Values, error) {
 		return nil, fmt.Errorf("query: Values() expects struct input. Got %v", val.Kind())
 	}
 
-	err := reflectValue(values, val, tagName, "")
+	err := reflectValue(values, val, tagName, "", style)
 	return values, err
 }
 
 // reflectValue populates the values parameter from the struct fields in val.
 // Embedded structs are followed recursively (using the rules defined in the
 // Values function documentation) breadth-first.
-func reflectValue(values url.Values, val reflect.Value, tagName string, scope string) error {
+func reflectValue(values url.Values, val reflect.Value, tagName string, scope string, style KeyStyle) error {
 	var embedded []reflect.Value
 
 	typ := val.Type()
@@ -179,7 +215,7 @@ func reflectValue(values url.Values, val reflect.Value, tagName string, scope st
 		}
 
 		if scope != "" {
-			name = scope + "[" + name + "]"
+			name = style.nestedKey(scope, name)
 		}
 
 		if opts.Contains("omitempty") && isEmptyValue(sv) {
@@ -209,6 +245,7 @@ func reflectValue(values url.Values, val reflect.Value, tagName string, scope st
 		}
 
 		if sv.Kind() == reflect.Slice || sv.Kind() == reflect.Array {
+			base := name
 			var del string
 			if opts.Contains("comma") {
 				del = ","
@@ -221,6 +258,7 @@ func reflectValue(values url.Values, val reflect.Value, tagName string, scope st
 			} else {
 				del = sf.Tag.Get("del")
 			}
+			tagged := del != "" || opts.Contains("brackets") || opts.Contains("numbered")
 
 			if del != "" {
 				s := new(bytes.Buffer)
@@ -240,6 +278,20 @@ func reflectValue(values url.Values, val reflect.Value, tagName string, scope st
 					if opts.Contains("numbered") {
 						k = fmt.Sprintf("%s%d", name, i)
 					}
+					if elem := reflect.Indirect(sv.Index(i)); elem.Kind() == reflect.Struct && elem.Type() != timeType {
+						if err := reflectValue(values, elem, tagName, fmt.Sprintf("%s[%d]", base, i), style); err != nil {
+							return err
+						}
+						continue
+					}
+					if !tagged {
+						switch style {
+						case KeyStyleBrackets:
+							k = base + "[]"
+						case KeyStyleIndexed:
+							k = fmt.Sprintf("%s[%d]", base, i)
+						}
+					}
 					values.Add(k, valueString(sv.Index(i), opts, sf))
 				}
 			}
@@ -252,7 +304,7 @@ func reflectValue(values url.Values, val reflect.Value, tagName string, scope st
 		}
 
 		if sv.Kind() == reflect.Struct {
-			if err := reflectValue(values, sv, tagName, name); err != nil {
+			if err := reflectValue(values, sv, tagName, name, style); err != nil {
 				return err
 			}
 			continue
@@ -262,7 +314,7 @@ func reflectValue(values url.Values, val reflect.Value, tagName string, scope st
 	}
 
 	for _, f := range embedded {
-		if err := reflectValue(values, f, tagName, scope); err != nil {
+		if err := reflectValue(values, f, tagName, scope, style); err != nil {
 			return err
 		}
 	}
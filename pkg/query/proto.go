@@ -0,0 +1,105 @@
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// wellKnownPackage is the package of the well-known types, they are encoded as their JSON form
+const wellKnownPackage = "google.protobuf"
+
+// ProtoValues returns the url.Values encoding of m following the query parameter
+// mapping of google.api.http:
+//
+//   - only the populated fields are encoded, including the set field of a oneof
+//   - the key is the proto field name, nested message fields are joined with dots, e.g. "user.name"
+//   - repeated fields are encoded as multiple values of the same key
+//   - map fields are encoded as "field[key]=value"
+//   - enums are encoded as their value name, bytes as standard base64
+//   - well-known types are encoded as their JSON form, e.g. a Timestamp as "2006-01-02T15:04:05Z"
+//     and a FieldMask as "a.b,c"
+func ProtoValues(m proto.Message) (url.Values, error) {
+	values := make(url.Values)
+	if m == nil || !m.ProtoReflect().IsValid() {
+		return values, nil
+	}
+	err := reflectMessage(values, m.ProtoReflect(), "")
+	return values, err
+}
+
+// reflectMessage populates values from the populated fields of m
+func reflectMessage(values url.Values, m protoreflect.Message, scope string) error {
+	var err error
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		name := string(fd.Name())
+		if scope != "" {
+			name = scope + "." + name
+		}
+		switch {
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len() && err == nil; i++ {
+				err = reflectField(values, fd, list.Get(i), name)
+			}
+		case fd.IsMap():
+			v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				err = reflectField(values, fd.MapValue(), v, name+"["+k.String()+"]")
+				return err == nil
+			})
+		default:
+			err = reflectField(values, fd, v, name)
+		}
+		return err == nil
+	})
+	return err
+}
+
+// reflectField populates values from a singular value of fd
+func reflectField(values url.Values, fd protoreflect.FieldDescriptor, v protoreflect.Value, name string) error {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if fd.Message().FullName().Parent() != wellKnownPackage {
+			return reflectMessage(values, v.Message(), name)
+		}
+		s, err := wellKnownString(v.Message())
+		if err != nil {
+			return err
+		}
+		values.Add(name, s)
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			values.Add(name, string(ev.Name()))
+		} else {
+			values.Add(name, strconv.Itoa(int(v.Enum())))
+		}
+	case protoreflect.BytesKind:
+		values.Add(name, base64.StdEncoding.EncodeToString(v.Bytes()))
+	case protoreflect.FloatKind:
+		values.Add(name, strconv.FormatFloat(v.Float(), 'g', -1, 32))
+	case protoreflect.DoubleKind:
+		values.Add(name, strconv.FormatFloat(v.Float(), 'g', -1, 64))
+	default:
+		values.Add(name, v.String())
+	}
+	return nil
+}
+
+// wellKnownString returns the JSON form of a well-known type, unquoted if it is a JSON string
+func wellKnownString(m protoreflect.Message) (string, error) {
+	b, err := protojson.Marshal(m.Interface())
+	if err != nil {
+		return "", fmt.Errorf("query: encode %s: %v", m.Descriptor().FullName(), err)
+	}
+	s := strings.TrimSpace(string(b))
+	if strings.HasPrefix(s, `"`) {
+		err = json.Unmarshal([]byte(s), &s)
+	}
+	return s, err
+}
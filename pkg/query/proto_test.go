@@ -0,0 +1,119 @@
+package query
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"testing"
+	"time"
+)
+
+func newDemoMessage(t *testing.T) protoreflect.Message {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Type:   typ.Enum(),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	ids := field("ids", 3, descriptorpb.FieldDescriptorProto_TYPE_INT64, "")
+	ids.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	counts := field("counts", 9, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".demo.Demo.CountsEntry")
+	counts.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	choiceA := field("a", 7, descriptorpb.FieldDescriptorProto_TYPE_STRING, "")
+	choiceA.OneofIndex = proto.Int32(0)
+	choiceB := field("b", 8, descriptorpb.FieldDescriptorProto_TYPE_INT32, "")
+	choiceB.OneofIndex = proto.Int32(0)
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("demo.proto"),
+		Package: proto.String("demo"),
+		Syntax:  proto.String("proto3"),
+		Dependency: []string{
+			"google/protobuf/timestamp.proto",
+			"google/protobuf/field_mask.proto",
+			"google/protobuf/wrappers.proto",
+		},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Status"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("STATUS_UNKNOWN"), Number: proto.Int32(0)},
+				{Name: proto.String("STATUS_ACTIVE"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Demo"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				field("status", 2, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".demo.Status"),
+				ids,
+				field("inner", 4, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".demo.Demo.Inner"),
+				field("at", 5, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Timestamp"),
+				field("mask", 6, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.FieldMask"),
+				choiceA,
+				choiceB,
+				counts,
+				field("data", 10, descriptorpb.FieldDescriptorProto_TYPE_BYTES, ""),
+				field("big", 11, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Int64Value"),
+				field("empty", 12, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+			},
+			OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String("choice")}},
+			NestedType: []*descriptorpb.DescriptorProto{
+				{Name: proto.String("Inner"), Field: []*descriptorpb.FieldDescriptorProto{
+					field("city", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				}},
+				{Name: proto.String("CountsEntry"), Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)}, Field: []*descriptorpb.FieldDescriptorProto{
+					field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+					field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, ""),
+				}},
+			},
+		}},
+	}
+	fd, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dynamicpb.NewMessage(fd.Messages().ByName("Demo"))
+}
+
+func Test_ProtoValues(t *testing.T) {
+	m := newDemoMessage(t)
+	fields := m.Descriptor().Fields()
+	m.Set(fields.ByName("name"), protoreflect.ValueOfString("张三"))
+	m.Set(fields.ByName("status"), protoreflect.ValueOfEnum(1))
+	ids := m.Mutable(fields.ByName("ids")).List()
+	ids.Append(protoreflect.ValueOfInt64(1))
+	ids.Append(protoreflect.ValueOfInt64(9007199254740993))
+	m.Mutable(fields.ByName("inner")).Message().Set(fields.ByName("inner").Message().Fields().ByName("city"), protoreflect.ValueOfString("SFO"))
+	m.Set(fields.ByName("at"), protoreflect.ValueOfMessage(timestamppb.New(time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)).ProtoReflect()))
+	m.Set(fields.ByName("mask"), protoreflect.ValueOfMessage((&fieldmaskpb.FieldMask{Paths: []string{"user.display_name", "age"}}).ProtoReflect()))
+	m.Set(fields.ByName("b"), protoreflect.ValueOfInt32(7))
+	m.Mutable(fields.ByName("counts")).Map().Set(protoreflect.ValueOfString("x").MapKey(), protoreflect.ValueOfInt32(2))
+	m.Set(fields.ByName("data"), protoreflect.ValueOfBytes([]byte{0xfb, 0xff}))
+	m.Set(fields.ByName("big"), protoreflect.ValueOfMessage(wrapperspb.Int64(42).ProtoReflect()))
+
+	values, err := ProtoValues(m.Interface())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "at=2022-01-02T03%3A04%3A05Z&b=7&big=42&counts%5Bx%5D=2&data=%2B%2F8%3D&ids=1&ids=9007199254740993" +
+		"&inner.city=SFO&mask=user.displayName%2Cage&name=%E5%BC%A0%E4%B8%89&status=STATUS_ACTIVE"
+	if encoded := values.Encode(); encoded != expect {
+		t.Fatalf("expect:%v,but get:%v", expect, encoded)
+	}
+
+	values, err = ProtoValues(nil)
+	if err != nil || len(values) != 0 {
+		t.Fatalf("expect empty values,but get:%v, err:%v", values, err)
+	}
+}
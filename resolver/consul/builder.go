@@ -2,8 +2,11 @@ package consul
 
 import (
 	"context"
+	"net/http"
 	"strings"
 
+	"github.com/classtorch/prpc/logger"
+	"github.com/classtorch/prpc/metrics"
 	"github.com/classtorch/prpc/resolver"
 	"github.com/hashicorp/consul/api"
 	"github.com/pkg/errors"
@@ -13,31 +16,91 @@ import (
 // All target URLs like 'consul://.../...' will be resolved by this resolver
 const schemeName = "consul"
 
+// builderOptions consul resolver builder options
+type builderOptions struct {
+	httpClient *http.Client
+	transport  *http.Transport
+	metrics    metrics.MetricsRecorder
+	log        logger.Logger
+}
+
+type BuilderOption func(*builderOptions)
+
+// WithHTTPClient use the given http.Client to talk to the Consul agent,
+// the TLS and timeout parameters of the target URL are ignored in this case
+func WithHTTPClient(client *http.Client) BuilderOption {
+	return func(o *builderOptions) {
+		o.httpClient = client
+	}
+}
+
+// WithTransport use a copy of the given http.Transport to talk to the Consul agent,
+// the TLS parameters of the target URL are applied on top of it unless it already has a TLSClientConfig
+func WithTransport(transport *http.Transport) BuilderOption {
+	return func(o *builderOptions) {
+		o.transport = transport
+	}
+}
+
+// WithMetricsRecorder record the endpoints updates and the errors of the watched services to recorder,
+// the services are the targets of the records
+func WithMetricsRecorder(recorder metrics.MetricsRecorder) BuilderOption {
+	return func(o *builderOptions) {
+		o.metrics = recorder
+	}
+}
+
+// WithLogger log with log instead of logger.Default, the logs are named consul and have the service as target field
+func WithLogger(log logger.Logger) BuilderOption {
+	return func(o *builderOptions) {
+		o.log = log
+	}
+}
+
 // new a resolver builder
-func NewResolverBuilder() resolver.Builder {
-	return &builder{}
+func NewResolverBuilder(opts ...BuilderOption) resolver.Builder {
+	b := &builder{opts: builderOptions{log: logger.Default()}}
+	for _, opt := range opts {
+		opt(&b.opts)
+	}
+	return b
 }
 
 // builder implements resolver.Builder and use for constructing all consul resolvers
-type builder struct{}
+type builder struct {
+	opts builderOptions
+}
 
 func (b *builder) Build(target resolver.Target, cc resolver.ClientConn) (resolver.Resolver, error) {
-	dsn := strings.Join([]string{schemeName + ":/", target.Agent, target.Endpoint}, "/")
+	dsn := target.URL.String()
+	if target.URL.Scheme == "" {
+		dsn = strings.Join([]string{schemeName + ":/", target.Agent, target.Endpoint}, "/")
+	}
 	tgt, err := parseURL(dsn)
 	if err != nil {
 		return nil, errors.Wrap(err, "Wrong consul URL")
 	}
-	cli, err := api.NewClient(tgt.consulConfig())
+	config, err := tgt.consulConfig(b.opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "Wrong consul config")
+	}
+	cli, err := api.NewClient(config)
 	if err != nil {
 		return nil, errors.Wrap(err, "Couldn't connect to the Consul API")
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	pipe := make(chan []string)
-	go watchConsulService(ctx, cli.Health(), tgt, pipe)
-	go populateEndpoints(ctx, cc, pipe)
+	pipe := make(chan endpointsUpdate)
+	log := b.opts.log.Named("consul").With("target", tgt.Service)
+	w := newWatcher(cli.Health(), tgt, log)
+	go w.watch(ctx, pipe)
+	recorder := b.opts.metrics
+	if recorder == nil {
+		recorder = metrics.NopRecorder{}
+	}
+	go populateEndpoints(ctx, cc, tgt, pipe, recorder, log)
 
-	return &Resolver{cancelFunc: cancel}, nil
+	return &Resolver{cancelFunc: cancel, watcher: w}, nil
 }
 
 // Scheme returns the scheme supported by this resolver.
@@ -50,10 +113,14 @@ func (b *builder) Scheme() string {
 // It watches for endpoints changes and pushes them to the underlying gRPC connection.
 type Resolver struct {
 	cancelFunc context.CancelFunc
+	watcher    *watcher
 }
 
-// ResolveNow will be skipped due unnecessary in this case
-func (r *Resolver) ResolveNow() {}
+// ResolveNow cancels the pending blocking query and fetches the endpoints at once.
+// It is rate-limited by the 'resolve-now-interval' URL parameter.
+func (r *Resolver) ResolveNow() {
+	r.watcher.resolveNow()
+}
 
 // Close closes the resolver.
 func (r *Resolver) Close() {
@@ -0,0 +1,49 @@
+package consul
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// endpointsCache is the on-disk format of the last known good endpoints
+type endpointsCache struct {
+	Addrs     []string  `json:"addrs"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// readCache load endpoints cache from file
+func readCache(file string) (endpointsCache, error) {
+	var cache endpointsCache
+	bys, err := ioutil.ReadFile(file)
+	if err != nil {
+		return cache, err
+	}
+	err = json.Unmarshal(bys, &cache)
+	return cache, err
+}
+
+// writeCache save endpoints cache to file, the file is replaced atomically
+// so a concurrent reader never sees a partial write
+func writeCache(file string, cache endpointsCache) error {
+	bys, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(bys); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
@@ -3,21 +3,105 @@ package consul
 import (
 	"context"
 	"fmt"
+	"github.com/classtorch/prpc/logger"
+	"github.com/classtorch/prpc/metrics"
 	"github.com/classtorch/prpc/resolver"
 	"github.com/hashicorp/consul/api"
 	"github.com/jpillora/backoff"
-	"log"
+	"github.com/pkg/errors"
+	"sync"
 	"time"
 )
 
+var (
+	// ErrStaleEndpoints is reported once the last known good endpoints are older than the staleness limit
+	ErrStaleEndpoints = errors.New("consul endpoints are stale")
+)
+
 //go:generate mockgen -package mocks -destination internal/mocks/resolverClientConn.go  google.golang.org/grpc/resolver ClientConn
 //go:generate mockgen -package mocks -destination internal/mocks/servicer.go -source consul.go servicer
 type servicer interface {
 	Service(string, string, bool, *api.QueryOptions) ([]*api.ServiceEntry, *api.QueryMeta, error)
 }
 
-func watchConsulService(ctx context.Context, s servicer, tgt target, out chan<- []string) {
-	res := make(chan []string)
+// endpointsUpdate result of a single Consul query
+type endpointsUpdate struct {
+	addrs []string
+	err   error
+}
+
+// watcher watches a Consul service with blocking queries,
+// a pending blocking query can be interrupted by resolveNow to re-resolve at once
+type watcher struct {
+	s   servicer
+	tgt target
+	log logger.Logger
+
+	mu             sync.Mutex
+	cancelPoll     context.CancelFunc // cancels the pending query
+	immediate      bool               // next query must not block
+	lastResolveNow time.Time
+	wake           chan struct{} // interrupts the backoff sleep
+}
+
+func newWatcher(s servicer, tgt target, log logger.Logger) *watcher {
+	return &watcher{
+		s:    s,
+		tgt:  tgt,
+		log:  log,
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// resolveNow cancels the pending blocking query and issues a non-blocking one,
+// calls within tgt.ResolveNowInterval of the previous one are dropped
+func (w *watcher) resolveNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := time.Now()
+	if now.Sub(w.lastResolveNow) < w.tgt.ResolveNowInterval {
+		return
+	}
+	w.lastResolveNow = now
+	w.immediate = true
+	if w.cancelPoll != nil {
+		w.cancelPoll()
+	}
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// nextPoll return the context of the next query and whether it must not block,
+// the query serves the pending resolveNow so its wake token is dropped, it would cut short the next backoff
+func (w *watcher) nextPoll(ctx context.Context) (context.Context, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	pollCtx, cancel := context.WithCancel(ctx)
+	w.cancelPoll = cancel
+	immediate := w.immediate
+	w.immediate = false
+	select {
+	case <-w.wake:
+	default:
+	}
+	return pollCtx, immediate
+}
+
+// donePoll release the context of the finished query
+func (w *watcher) donePoll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cancelPoll != nil {
+		w.cancelPoll()
+		w.cancelPoll = nil
+	}
+}
+
+func (w *watcher) watch(ctx context.Context, out chan<- endpointsUpdate) {
+	tgt := w.tgt
+	res := make(chan endpointsUpdate)
 	quit := make(chan struct{})
 	bck := &backoff.Backoff{
 		Factor: 2,
@@ -28,31 +112,51 @@ func watchConsulService(ctx context.Context, s servicer, tgt target, out chan<-
 	go func() {
 		var lastIndex uint64
 		for {
-			entries, meta, err := s.Service(
+			pollCtx, immediate := w.nextPoll(ctx)
+			waitIndex := lastIndex
+			if immediate {
+				waitIndex = 0
+			}
+			entries, meta, err := w.s.Service(
 				tgt.Service,
 				tgt.Tag,
 				tgt.Healthy,
-				&api.QueryOptions{
-					WaitIndex:         lastIndex,
+				(&api.QueryOptions{
+					WaitIndex:         waitIndex,
 					Near:              tgt.Near,
 					WaitTime:          tgt.Wait,
 					Datacenter:        tgt.Dc,
 					AllowStale:        tgt.AllowStale,
 					RequireConsistent: tgt.RequireConsistent,
-				},
+				}).WithContext(pollCtx),
 			)
+			interrupted := pollCtx.Err() != nil
+			w.donePoll()
+			if ctx.Err() != nil {
+				return
+			}
 			if err != nil {
-				log.Printf("[Consul resolver] Couldn't fetch endpoints. target={%s}; error={%v}", tgt.String(), err)
-				time.Sleep(bck.Duration())
+				if interrupted {
+					// canceled by resolveNow, query again at once
+					continue
+				}
+				w.log.Warn("couldn't fetch endpoints", "err", err)
+				select {
+				case res <- endpointsUpdate{err: err}:
+				case <-quit:
+					return
+				}
+				select {
+				case <-time.After(bck.Duration()):
+				case <-w.wake:
+				case <-quit:
+					return
+				}
 				continue
 			}
 			bck.Reset()
 			lastIndex = meta.LastIndex
-			log.Printf("[Consul resolver] %d endpoints fetched in(+wait) %s for target={%s}",
-				len(entries),
-				meta.RequestTime,
-				tgt.String(),
-			)
+			w.log.Debug("endpoints fetched", "count", len(entries), "request_time", meta.RequestTime)
 
 			addrs := make([]string, 0, len(entries))
 			for _, s := range entries {
@@ -68,7 +172,7 @@ func watchConsulService(ctx context.Context, s servicer, tgt target, out chan<-
 				addrs = addrs[:tgt.Limit]
 			}
 			select {
-			case res <- addrs:
+			case res <- endpointsUpdate{addrs: addrs}:
 				continue
 			case <-quit:
 				return
@@ -79,29 +183,76 @@ func watchConsulService(ctx context.Context, s servicer, tgt target, out chan<-
 	for {
 		select {
 		case ee := <-res:
-			out <- ee
+			select {
+			case out <- ee:
+				continue
+			case <-ctx.Done():
+			}
 		case <-ctx.Done():
-			// Close quit so the goroutine returns and doesn't leak.
-			// Do NOT close res because that can lead to panics in the goroutine.
-			// res will be garbage collected at some point.
-			close(quit)
-			return
 		}
+		// Close quit so the goroutine returns and doesn't leak.
+		// Do NOT close res because that can lead to panics in the goroutine.
+		// res will be garbage collected at some point.
+		close(quit)
+		return
 	}
 }
 
-func populateEndpoints(ctx context.Context, cc resolver.ClientConn, input <-chan []string) {
+// populateEndpoints pushes fetched endpoints to the ClientConn and reports fetch errors.
+// The last known good endpoints stay in use until they are older than tgt.StalenessLimit,
+// if there are none yet they are loaded from tgt.CacheFile.
+func populateEndpoints(ctx context.Context, cc resolver.ClientConn, tgt target, input <-chan endpointsUpdate, recorder metrics.MetricsRecorder, log logger.Logger) {
+	var (
+		lastGood  time.Time // when the endpoints in use were fetched
+		published bool      // whether the endpoints in use are still valid
+	)
 	for {
 		select {
 		case data := <-input:
-			addresses := make([]resolver.Address, len(data))
-			for i, addr := range data {
-				addresses[i] = resolver.Address{Addr: addr}
+			if data.err != nil {
+				if !published && lastGood.IsZero() && len(tgt.CacheFile) > 0 {
+					cached, err := readCache(tgt.CacheFile)
+					if err != nil {
+						log.Warn("couldn't read cache", "file", tgt.CacheFile, "err", err)
+					} else if len(cached.Addrs) > 0 {
+						log.Info("endpoints loaded from cache", "count", len(cached.Addrs), "updated_at", cached.UpdatedAt)
+						cc.UpdateState(buildState(cached.Addrs))
+						lastGood, published = cached.UpdatedAt, true
+					}
+				}
+				if published && tgt.StalenessLimit > 0 && time.Since(lastGood) > tgt.StalenessLimit {
+					// drop the stale endpoints so the error below makes calls fail fast
+					cc.UpdateState(resolver.State{})
+					published = false
+				}
+				err := errors.Wrapf(data.err, "target={%s}", tgt.String())
+				if !published && !lastGood.IsZero() && tgt.StalenessLimit > 0 {
+					err = errors.Wrapf(ErrStaleEndpoints, "last update at %s, %v", lastGood, err)
+				}
+				cc.ReportError(err)
+				recorder.ResolverUpdate(tgt.Service, err)
+				continue
+			}
+			lastGood, published = time.Now(), true
+			cc.UpdateState(buildState(data.addrs))
+			recorder.ResolverUpdate(tgt.Service, nil)
+			if len(tgt.CacheFile) > 0 {
+				if err := writeCache(tgt.CacheFile, endpointsCache{Addrs: data.addrs, UpdatedAt: lastGood}); err != nil {
+					log.Warn("couldn't write cache", "file", tgt.CacheFile, "err", err)
+				}
 			}
-			cc.UpdateState(resolver.State{Addresses: addresses})
 		case <-ctx.Done():
-			log.Printf("[Consul resolver] Watch has been finished")
+			log.Debug("watch has been finished")
 			return
 		}
 	}
 }
+
+// buildState convert endpoints to resolver State
+func buildState(addrs []string) resolver.State {
+	addresses := make([]resolver.Address, len(addrs))
+	for i, addr := range addrs {
+		addresses[i] = resolver.Address{Addr: addr}
+	}
+	return resolver.State{Addresses: addresses}
+}
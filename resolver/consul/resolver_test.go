@@ -0,0 +1,236 @@
+package consul
+
+import (
+	"context"
+	"errors"
+	"github.com/classtorch/prpc/logger"
+	"github.com/classtorch/prpc/metrics"
+	"github.com/classtorch/prpc/resolver"
+	"github.com/hashicorp/consul/api"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeResponse a response of fakeServicer
+type fakeResponse struct {
+	addrs []string
+	index uint64
+	err   error
+}
+
+// fakeServicer answer the queries with the responses sent to it, a query blocks until there is one
+type fakeServicer struct {
+	queries   chan *api.QueryOptions
+	responses chan fakeResponse
+}
+
+func newFakeServicer() *fakeServicer {
+	return &fakeServicer{queries: make(chan *api.QueryOptions, 16), responses: make(chan fakeResponse)}
+}
+
+func (s *fakeServicer) Service(service, tag string, passingOnly bool, q *api.QueryOptions) ([]*api.ServiceEntry, *api.QueryMeta, error) {
+	s.queries <- q
+	select {
+	case res := <-s.responses:
+		if res.err != nil {
+			return nil, nil, res.err
+		}
+		entries := make([]*api.ServiceEntry, len(res.addrs))
+		for i, addr := range res.addrs {
+			entries[i] = &api.ServiceEntry{Node: &api.Node{Address: addr}, Service: &api.AgentService{Port: 8000}}
+		}
+		return entries, &api.QueryMeta{LastIndex: res.index}, nil
+	case <-q.Context().Done():
+		return nil, nil, q.Context().Err()
+	}
+}
+
+// fakeClientConn record the states and the errors of the resolver
+type fakeClientConn struct {
+	states chan resolver.State
+	errs   chan error
+}
+
+func newFakeClientConn() *fakeClientConn {
+	return &fakeClientConn{states: make(chan resolver.State, 16), errs: make(chan error, 16)}
+}
+
+func (cc *fakeClientConn) UpdateState(state resolver.State) error {
+	cc.states <- state
+	return nil
+}
+
+func (cc *fakeClientConn) ReportError(err error) {
+	cc.errs <- err
+}
+
+func (cc *fakeClientConn) state(t *testing.T) resolver.State {
+	t.Helper()
+	select {
+	case state := <-cc.states:
+		return state
+	case <-time.After(time.Second):
+		t.Fatal("expect a state update")
+	}
+	return resolver.State{}
+}
+
+func (cc *fakeClientConn) err(t *testing.T) error {
+	t.Helper()
+	select {
+	case err := <-cc.errs:
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("expect an error")
+	}
+	return nil
+}
+
+// startWatch watch tgt with s and populate the endpoints to a fakeClientConn until the test ends
+func startWatch(t *testing.T, s servicer, tgt target) (*watcher, *fakeClientConn) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	cc := newFakeClientConn()
+	pipe := make(chan endpointsUpdate)
+	w := newWatcher(s, tgt, logger.NopLogger{})
+	go w.watch(ctx, pipe)
+	go populateEndpoints(ctx, cc, tgt, pipe, metrics.NopRecorder{}, logger.NopLogger{})
+	return w, cc
+}
+
+func expectAddrs(t *testing.T, state resolver.State, addrs ...string) {
+	t.Helper()
+	if len(state.Addresses) != len(addrs) {
+		t.Fatalf("expect:%v,but get:%v", addrs, state.Addresses)
+	}
+	for i, addr := range addrs {
+		if state.Addresses[i].Addr != addr {
+			t.Fatalf("expect:%v,but get:%v", addrs, state.Addresses)
+		}
+	}
+}
+
+func Test_ErrorKeepsEndpoints(t *testing.T) {
+	s := newFakeServicer()
+	_, cc := startWatch(t, s, target{Service: "users", MaxBackoff: 20 * time.Millisecond, StalenessLimit: time.Hour})
+	s.responses <- fakeResponse{addrs: []string{"10.0.0.1"}, index: 1}
+	expectAddrs(t, cc.state(t), "10.0.0.1:8000")
+
+	s.responses <- fakeResponse{err: errors.New("consul unavailable")}
+	if err := cc.err(t); errors.Is(err, ErrStaleEndpoints) {
+		t.Fatalf("expect:the fetch error,but get:%v", err)
+	}
+	select {
+	case state := <-cc.states:
+		t.Fatalf("expect:the endpoints kept,but get:%v", state)
+	default:
+	}
+}
+
+func Test_StaleEndpointsDropped(t *testing.T) {
+	s := newFakeServicer()
+	_, cc := startWatch(t, s, target{Service: "users", MaxBackoff: 20 * time.Millisecond, StalenessLimit: 50 * time.Millisecond})
+	s.responses <- fakeResponse{addrs: []string{"10.0.0.1"}, index: 1}
+	expectAddrs(t, cc.state(t), "10.0.0.1:8000")
+
+	time.Sleep(60 * time.Millisecond)
+	s.responses <- fakeResponse{err: errors.New("consul unavailable")}
+	expectAddrs(t, cc.state(t))
+	if err := cc.err(t); !errors.Is(err, ErrStaleEndpoints) {
+		t.Fatalf("expect:%v,but get:%v", ErrStaleEndpoints, err)
+	}
+}
+
+func Test_CacheLoadedOnStart(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "users.json")
+	if err := writeCache(file, endpointsCache{Addrs: []string{"10.0.0.1:8000"}, UpdatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	s := newFakeServicer()
+	_, cc := startWatch(t, s, target{Service: "users", MaxBackoff: 20 * time.Millisecond, CacheFile: file})
+	s.responses <- fakeResponse{err: errors.New("consul unavailable")}
+	expectAddrs(t, cc.state(t), "10.0.0.1:8000")
+	if err := cc.err(t); err == nil || errors.Is(err, ErrStaleEndpoints) {
+		t.Fatalf("expect:the fetch error,but get:%v", err)
+	}
+
+	// the fetched endpoints replace the cached ones
+	s.responses <- fakeResponse{addrs: []string{"10.0.0.2"}, index: 1}
+	expectAddrs(t, cc.state(t), "10.0.0.2:8000")
+	deadline := time.Now().Add(time.Second)
+	for {
+		cache, err := readCache(file)
+		if err == nil && len(cache.Addrs) == 1 && cache.Addrs[0] == "10.0.0.2:8000" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expect:[10.0.0.2:8000] cached,but get:%v %v", cache.Addrs, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// nextQuery return the next query sent to s
+func (s *fakeServicer) nextQuery(t *testing.T) *api.QueryOptions {
+	t.Helper()
+	select {
+	case q := <-s.queries:
+		return q
+	case <-time.After(time.Second):
+		t.Fatal("expect a query")
+	}
+	return nil
+}
+
+func Test_ResolveNowInterruptsQuery(t *testing.T) {
+	s := newFakeServicer()
+	w, cc := startWatch(t, s, target{Service: "users", MaxBackoff: 20 * time.Millisecond})
+	s.nextQuery(t)
+	s.responses <- fakeResponse{addrs: []string{"10.0.0.1"}, index: 5}
+	expectAddrs(t, cc.state(t), "10.0.0.1:8000")
+
+	// the blocking query waits for a change after index 5 until resolveNow cancels it
+	if q := s.nextQuery(t); q.WaitIndex != 5 {
+		t.Fatalf("expect:wait index 5,but get:%d", q.WaitIndex)
+	}
+	w.resolveNow()
+	if q := s.nextQuery(t); q.WaitIndex != 0 {
+		t.Fatalf("expect:a non-blocking query,but get wait index:%d", q.WaitIndex)
+	}
+	s.responses <- fakeResponse{addrs: []string{"10.0.0.2"}, index: 6}
+	expectAddrs(t, cc.state(t), "10.0.0.2:8000")
+	if q := s.nextQuery(t); q.WaitIndex != 6 {
+		t.Fatalf("expect:wait index 6,but get:%d", q.WaitIndex)
+	}
+}
+
+func Test_ResolveNowInterval(t *testing.T) {
+	s := newFakeServicer()
+	w, _ := startWatch(t, s, target{Service: "users", MaxBackoff: 20 * time.Millisecond, ResolveNowInterval: time.Hour})
+	s.nextQuery(t)
+	w.resolveNow()
+	if q := s.nextQuery(t); q.WaitIndex != 0 {
+		t.Fatalf("expect:a non-blocking query,but get wait index:%d", q.WaitIndex)
+	}
+	// within the interval the pending query is not interrupted
+	w.resolveNow()
+	select {
+	case q := <-s.queries:
+		t.Fatalf("expect:no query,but get:%v", q)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func Test_ResolveNowWakeDrained(t *testing.T) {
+	w := newWatcher(newFakeServicer(), target{Service: "users"}, logger.NopLogger{})
+	w.resolveNow()
+	_, immediate := w.nextPoll(context.Background())
+	if !immediate {
+		t.Fatal("expect:a non-blocking query after resolveNow")
+	}
+	// the token of the resolveNow served by the query must not cut short a later backoff
+	if len(w.wake) != 0 {
+		t.Fatalf("expect:no wake token,but get:%d", len(w.wake))
+	}
+}
@@ -13,24 +13,33 @@ import (
 )
 
 type target struct {
-	Addr              string        `form:"-"`
-	User              string        `form:"-"`
-	Password          string        `form:"-"`
-	Service           string        `form:"-"`
-	Wait              time.Duration `form:"wait"`
-	Timeout           time.Duration `form:"timeout"`
-	MaxBackoff        time.Duration `form:"max-backoff"`
-	Tag               string        `form:"tag"`
-	Near              string        `form:"near"`
-	Limit             int           `form:"limit"`
-	Healthy           bool          `form:"healthy"`
-	TLSInsecure       bool          `form:"insecure"`
-	Token             string        `form:"token"`
-	Dc                string        `form:"dc"`
-	AllowStale        bool          `form:"allow-stale"`
-	RequireConsistent bool          `form:"require-consistent"`
-	// TODO(mbobakov): custom parameters for the http-transport
-	// TODO(mbobakov): custom parameters for the TLS subsystem
+	Addr               string        `form:"-"`
+	User               string        `form:"-"`
+	Password           string        `form:"-"`
+	Service            string        `form:"-"`
+	Wait               time.Duration `form:"wait"`
+	Timeout            time.Duration `form:"timeout"`
+	MaxBackoff         time.Duration `form:"max-backoff"`
+	Tag                string        `form:"tag"`
+	Near               string        `form:"near"`
+	Limit              int           `form:"limit"`
+	Healthy            bool          `form:"healthy"`
+	TLS                bool          `form:"tls"`
+	TLSInsecure        bool          `form:"insecure"`
+	CAFile             string        `form:"ca-file"`
+	CAPath             string        `form:"ca-path"`
+	CertFile           string        `form:"cert-file"`
+	KeyFile            string        `form:"key-file"`
+	ServerName         string        `form:"server-name"`
+	Token              string        `form:"token"`
+	Dc                 string        `form:"dc"`
+	Namespace          string        `form:"namespace"`
+	Partition          string        `form:"partition"`
+	AllowStale         bool          `form:"allow-stale"`
+	RequireConsistent  bool          `form:"require-consistent"`
+	CacheFile          string        `form:"cache-file"`
+	StalenessLimit     time.Duration `form:"staleness-limit"`
+	ResolveNowInterval time.Duration `form:"resolve-now-interval"`
 }
 
 func (t *target) String() string {
@@ -72,30 +81,73 @@ func parseURL(u string) (target, error) {
 	if tgt.MaxBackoff == 0 {
 		tgt.MaxBackoff = time.Second
 	}
+	if tgt.ResolveNowInterval == 0 {
+		tgt.ResolveNowInterval = time.Second
+	}
+	if (len(tgt.CertFile) == 0) != (len(tgt.KeyFile) == 0) {
+		return target{}, errors.New("Malformed URL parameters: 'cert-file' and 'key-file' must be set together")
+	}
 	return tgt, nil
 }
 
+// useTLS reports whether the agent must be reached over https
+func (t *target) useTLS() bool {
+	return t.TLS || len(t.CAFile) > 0 || len(t.CAPath) > 0 || len(t.CertFile) > 0
+}
+
 // consulConfig returns config based on the parsed target.
-// It uses custom http-client.
-func (t *target) consulConfig() *api.Config {
+// It uses custom http-client, the one injected by WithHTTPClient takes precedence over
+// the transport and TLS parameters of the target.
+func (t *target) consulConfig(opts builderOptions) (*api.Config, error) {
 	var creds *api.HttpBasicAuth
 	if len(t.User) > 0 && len(t.Password) > 0 {
 		creds = new(api.HttpBasicAuth)
 		creds.Password = t.Password
 		creds.Username = t.User
 	}
-	// custom http.Client
-	c := &http.Client{
-		Timeout: t.Timeout,
+	tlsConfig := api.TLSConfig{
+		Address:            t.ServerName,
+		CAFile:             t.CAFile,
+		CAPath:             t.CAPath,
+		CertFile:           t.CertFile,
+		KeyFile:            t.KeyFile,
+		InsecureSkipVerify: t.TLSInsecure,
+	}
+	scheme := "http"
+	if t.useTLS() {
+		scheme = "https"
+	}
+
+	c := opts.httpClient
+	if c == nil {
+		var transport *http.Transport
+		if opts.transport != nil {
+			// api.NewHttpClient fills TLSClientConfig in place, never touch the caller's transport
+			ownTLS := opts.transport.TLSClientConfig != nil
+			transport = opts.transport.Clone()
+			if !ownTLS {
+				// Clone may set up a TLSClientConfig for HTTP/2, api.NewHttpClient would keep it
+				transport.TLSClientConfig = nil
+			}
+		} else {
+			transport = api.DefaultConfig().Transport
+		}
+		var err error
+		c, err = api.NewHttpClient(transport, tlsConfig)
+		if err != nil {
+			return nil, errors.Wrap(err, "Couldn't setup the TLS config")
+		}
+		c.Timeout = t.Timeout
 	}
 	return &api.Config{
 		Address:    t.Addr,
+		Scheme:     scheme,
 		HttpAuth:   creds,
 		WaitTime:   t.Wait,
 		HttpClient: c,
-		TLSConfig: api.TLSConfig{
-			InsecureSkipVerify: t.TLSInsecure,
-		},
-		Token: t.Token,
-	}
+		TLSConfig:  tlsConfig,
+		Token:      t.Token,
+		Namespace:  t.Namespace,
+		Partition:  t.Partition,
+	}, nil
 }
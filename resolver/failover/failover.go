@@ -0,0 +1,156 @@
+// Package failover implements a resolver which resolves a primary target
+// and falls back to a secondary target while the primary one has no addresses.
+package failover
+
+import (
+	"sync"
+
+	"github.com/classtorch/prpc/resolver"
+	"github.com/classtorch/prpc/resolver/internal/child"
+)
+
+// schemeName for the urls
+// All target URLs like 'failover:///service' will be resolved by this resolver
+const schemeName = "failover"
+
+const (
+	primaryIndex = iota
+	secondaryIndex
+)
+
+// NewResolverBuilder new a resolver builder resolving primary and secondary targets,
+// such as "consul://127.0.0.1:8500/uclass-account" and "127.0.0.1:8000".
+// builders are used before the resolvers of the ClientConn to resolve them.
+func NewResolverBuilder(primary, secondary string, builders ...resolver.Builder) resolver.Builder {
+	return &builder{
+		targets:  [2]string{primary, secondary},
+		builders: builders,
+	}
+}
+
+// builder implements resolver.Builder
+type builder struct {
+	targets  [2]string
+	builders []resolver.Builder
+}
+
+// Build build a failover resolver, the endpoint of target is ignored
+func (b *builder) Build(target resolver.Target, cc resolver.ClientConn) (resolver.Resolver, error) {
+	r := &Resolver{cc: cc}
+	for i, t := range b.targets {
+		childResolver, err := child.Build(t, &child.ClientConn{Index: i, UpdateFunc: r.updateState, ReportFunc: r.reportError, Parent: cc}, b.builders)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.mu.Lock()
+		r.children[i] = childResolver
+		r.mu.Unlock()
+	}
+	return r, nil
+}
+
+// Scheme returns the scheme supported by this resolver.
+func (b *builder) Scheme() string {
+	return schemeName
+}
+
+// childState latest state of a child resolver
+type childState struct {
+	state resolver.State
+	err   error
+}
+
+// usable whether the child state can be published
+func (s childState) usable() bool {
+	return s.err == nil && len(s.state.Addresses) > 0
+}
+
+// Resolver implements resolver.Resolver
+type Resolver struct {
+	cc       resolver.ClientConn
+	mu       sync.Mutex
+	children [2]resolver.Resolver
+	states   [2]childState
+	// publishing is set while a caller publishes the states, dirty when they changed since its last publish
+	publishing bool
+	dirty      bool
+}
+
+func (r *Resolver) updateState(index int, state resolver.State) {
+	r.mu.Lock()
+	r.states[index] = childState{state: state}
+	r.publish()
+}
+
+func (r *Resolver) reportError(index int, err error) {
+	r.mu.Lock()
+	r.states[index].err = err
+	r.publish()
+}
+
+// publish push the latest states to cc, it is called with mu held and releases it.
+// cc is called without mu by one caller at a time, the states changed meanwhile, e.g. by cc
+// calling ResolveNow, are pushed by the same caller once cc returns.
+func (r *Resolver) publish() {
+	r.dirty = true
+	if r.publishing {
+		r.mu.Unlock()
+		return
+	}
+	r.publishing = true
+	for r.dirty {
+		r.dirty = false
+		state, err := r.current()
+		r.mu.Unlock()
+		if err != nil {
+			r.cc.ReportError(err)
+		} else {
+			r.cc.UpdateState(state)
+		}
+		r.mu.Lock()
+	}
+	r.publishing = false
+	r.mu.Unlock()
+}
+
+// current return the primary state when usable, otherwise the secondary one, or the error to report
+func (r *Resolver) current() (resolver.State, error) {
+	primary, secondary := r.states[primaryIndex], r.states[secondaryIndex]
+	switch {
+	case primary.usable():
+		return primary.state, nil
+	case secondary.usable():
+		return secondary.state, nil
+	case primary.err != nil:
+		return resolver.State{}, primary.err
+	case secondary.err != nil:
+		return resolver.State{}, secondary.err
+	default:
+		return primary.state, nil
+	}
+}
+
+// ResolveNow forwards ResolveNow to both targets
+func (r *Resolver) ResolveNow() {
+	r.mu.Lock()
+	children := r.children
+	r.mu.Unlock()
+	for _, c := range children {
+		if c != nil {
+			c.ResolveNow()
+		}
+	}
+}
+
+// Close closes both targets resolvers
+func (r *Resolver) Close() {
+	r.mu.Lock()
+	children := r.children
+	r.mu.Unlock()
+	for _, c := range children {
+		if c != nil {
+			c.Close()
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package failover
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/classtorch/prpc/resolver"
+)
+
+type mockClientConn struct {
+	state resolver.State
+	err   error
+}
+
+func (cc *mockClientConn) UpdateState(state resolver.State) error {
+	cc.state, cc.err = state, nil
+	return nil
+}
+
+func (cc *mockClientConn) ReportError(err error) {
+	cc.err = err
+}
+
+type mockResolverBuilder struct {
+	cc resolver.ClientConn
+}
+
+func (b *mockResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn) (resolver.Resolver, error) {
+	b.cc = cc
+	return mockResolver{}, nil
+}
+
+func (b *mockResolverBuilder) Scheme() string {
+	return "consul"
+}
+
+type mockResolver struct {
+}
+
+func (mockResolver mockResolver) ResolveNow() {
+}
+
+func (mockResolver mockResolver) Close() {
+}
+
+// reentrantClientConn call ResolveNow then Close on its resolver from its first updates
+type reentrantClientConn struct {
+	mockClientConn
+	r       resolver.Resolver
+	updates int
+}
+
+func (cc *reentrantClientConn) UpdateState(state resolver.State) error {
+	cc.mockClientConn.UpdateState(state)
+	cc.updates++
+	if cc.r != nil && cc.updates < 4 {
+		cc.r.ResolveNow()
+		cc.r.Close()
+	}
+	return nil
+}
+
+// resolveNowBuilder build resolvers giving addresses to their ClientConn from ResolveNow
+type resolveNowBuilder struct {
+	addr string
+	cc   resolver.ClientConn
+}
+
+func (b *resolveNowBuilder) Build(target resolver.Target, cc resolver.ClientConn) (resolver.Resolver, error) {
+	b.cc = cc
+	return resolveNowResolver{b}, nil
+}
+
+func (b *resolveNowBuilder) Scheme() string {
+	return "consul"
+}
+
+type resolveNowResolver struct {
+	b *resolveNowBuilder
+}
+
+func (r resolveNowResolver) ResolveNow() {
+	r.b.cc.UpdateState(resolver.State{Addresses: []resolver.Address{{Addr: r.b.addr}}})
+}
+
+func (r resolveNowResolver) Close() {
+}
+
+func Test_Failover(t *testing.T) {
+	primary := &mockResolverBuilder{}
+	cc := &mockClientConn{}
+	_, err := NewResolverBuilder("consul://127.0.0.1:8500/account", "127.0.0.1:8000", primary).Build(resolver.Target{}, cc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cc.state.Addresses) != 1 || cc.state.Addresses[0].Addr != "127.0.0.1:8000" {
+		t.Fatalf("expect secondary address but get:%v", cc.state.Addresses)
+	}
+	primary.cc.UpdateState(resolver.State{Addresses: []resolver.Address{{Addr: "127.0.0.2:8000"}}})
+	if len(cc.state.Addresses) != 1 || cc.state.Addresses[0].Addr != "127.0.0.2:8000" {
+		t.Fatalf("expect primary address but get:%v", cc.state.Addresses)
+	}
+	primary.cc.ReportError(errors.New("consul unreachable"))
+	if cc.err != nil || cc.state.Addresses[0].Addr != "127.0.0.1:8000" {
+		t.Fatalf("expect secondary address but get:%v, err:%v", cc.state.Addresses, cc.err)
+	}
+	primary.cc.UpdateState(resolver.State{})
+	if cc.state.Addresses[0].Addr != "127.0.0.1:8000" {
+		t.Fatalf("expect secondary address but get:%v", cc.state.Addresses)
+	}
+}
+
+func Test_ReentrantClientConn(t *testing.T) {
+	primary := &resolveNowBuilder{addr: "127.0.0.2:8000"}
+	cc := &reentrantClientConn{}
+	r, err := NewResolverBuilder("consul://127.0.0.1:8500/account", "127.0.0.1:8000", primary).Build(resolver.Target{}, cc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc.r = r
+
+	// the ClientConn calls back the resolver, whose primary target resolves again from ResolveNow
+	done := make(chan struct{})
+	go func() {
+		primary.cc.UpdateState(resolver.State{Addresses: []resolver.Address{{Addr: "127.0.0.3:8000"}}})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expect:the update to return,but it is blocked")
+	}
+	if len(cc.state.Addresses) != 1 || cc.state.Addresses[0].Addr != "127.0.0.2:8000" {
+		t.Fatalf("expect:the latest primary address,but get:%v", cc.state.Addresses)
+	}
+}
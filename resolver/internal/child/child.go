@@ -0,0 +1,65 @@
+// Package child builds the resolvers composed by the meta-resolvers in resolver/.
+package child
+
+import (
+	"github.com/classtorch/prpc/resolver"
+)
+
+// Build build a resolver for target, builders are looked up by scheme before the ones of cc,
+// see resolver.LookupBuilder.
+// A target without scheme resolves to itself.
+func Build(target string, cc resolver.ClientConn, builders []resolver.Builder) (resolver.Resolver, error) {
+	parsedTarget, err := resolver.ParseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	if parsedTarget.Scheme == resolver.GetPassThroughScheme() {
+		if err := cc.UpdateState(resolver.State{Addresses: []resolver.Address{{Addr: parsedTarget.Endpoint}}}); err != nil {
+			return nil, err
+		}
+		return staticResolver{}, nil
+	}
+	var rb resolver.Builder
+	for _, b := range builders {
+		if b.Scheme() == parsedTarget.Scheme {
+			rb = b
+			break
+		}
+	}
+	if rb == nil {
+		rb = resolver.LookupBuilder(cc, parsedTarget.Scheme)
+	}
+	if rb == nil {
+		return nil, resolver.ResolverNotExistErr
+	}
+	return rb.Build(parsedTarget, cc)
+}
+
+// ClientConn is the resolver.ClientConn handed to a child resolver,
+// it tags every update with the index of the child and looks up the resolver Builders like Parent
+type ClientConn struct {
+	Index      int
+	UpdateFunc func(index int, state resolver.State)
+	ReportFunc func(index int, err error)
+	Parent     resolver.ClientConn
+}
+
+func (c *ClientConn) UpdateState(state resolver.State) error {
+	c.UpdateFunc(c.Index, state)
+	return nil
+}
+
+func (c *ClientConn) ReportError(err error) {
+	c.ReportFunc(c.Index, err)
+}
+
+func (c *ClientConn) ResolverBuilder(scheme string) resolver.Builder {
+	return resolver.LookupBuilder(c.Parent, scheme)
+}
+
+// staticResolver resolver of a target without scheme
+type staticResolver struct{}
+
+func (staticResolver) ResolveNow() {}
+
+func (staticResolver) Close() {}
@@ -0,0 +1,152 @@
+package child
+
+import (
+	"errors"
+	"github.com/classtorch/prpc/resolver"
+	"testing"
+)
+
+type mockClientConn struct {
+	state resolver.State
+	err   error
+}
+
+func (cc *mockClientConn) UpdateState(state resolver.State) error {
+	cc.state, cc.err = state, nil
+	return nil
+}
+
+func (cc *mockClientConn) ReportError(err error) {
+	cc.err = err
+}
+
+// mockResolverBuilder build resolvers of scheme giving addresses to their ClientConn
+type mockResolverBuilder struct {
+	scheme    string
+	addresses []string
+	ccs       []resolver.ClientConn
+}
+
+func (b *mockResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn) (resolver.Resolver, error) {
+	b.ccs = append(b.ccs, cc)
+	if len(b.addresses) > 0 {
+		state := resolver.State{}
+		for _, addr := range b.addresses {
+			state.Addresses = append(state.Addresses, resolver.Address{Addr: addr})
+		}
+		cc.UpdateState(state)
+	}
+	return mockResolver{}, nil
+}
+
+func (b *mockResolverBuilder) Scheme() string {
+	return b.scheme
+}
+
+// lookupClientConn look up the resolver Builders in its own registry
+type lookupClientConn struct {
+	mockClientConn
+	registry *resolver.Registry
+}
+
+func (cc *lookupClientConn) ResolverBuilder(scheme string) resolver.Builder {
+	return cc.registry.Get(scheme)
+}
+
+type mockResolver struct {
+}
+
+func (mockResolver mockResolver) ResolveNow() {
+}
+
+func (mockResolver mockResolver) Close() {
+}
+
+func Test_BuildPassThrough(t *testing.T) {
+	cc := &mockClientConn{}
+	r, err := Build("127.0.0.1:8000", cc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if len(cc.state.Addresses) != 1 || cc.state.Addresses[0].Addr != "127.0.0.1:8000" {
+		t.Fatalf("expect:[127.0.0.1:8000],but get:%v", cc.state.Addresses)
+	}
+}
+
+func Test_BuildBuilders(t *testing.T) {
+	registered := &mockResolverBuilder{scheme: "child-test"}
+	resolver.Register(registered)
+	defer resolver.Unregister(registered.scheme)
+
+	// the given builders are looked up before the registered ones
+	given := &mockResolverBuilder{scheme: "child-test", addresses: []string{"127.0.0.1:8000"}}
+	cc := &mockClientConn{}
+	if _, err := Build("child-test://127.0.0.1:8500/account", cc, []resolver.Builder{&mockResolverBuilder{scheme: "other"}, given}); err != nil {
+		t.Fatal(err)
+	}
+	if len(given.ccs) != 1 || len(registered.ccs) != 0 {
+		t.Fatalf("expect:the given builder used,but get:%d given and %d registered builds", len(given.ccs), len(registered.ccs))
+	}
+	if len(cc.state.Addresses) != 1 || cc.state.Addresses[0].Addr != "127.0.0.1:8000" {
+		t.Fatalf("expect:[127.0.0.1:8000],but get:%v", cc.state.Addresses)
+	}
+
+	if _, err := Build("child-test://127.0.0.1:8500/account", &mockClientConn{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(registered.ccs) != 1 {
+		t.Fatalf("expect:the registered builder used,but get:%d builds", len(registered.ccs))
+	}
+}
+
+func Test_BuildLookup(t *testing.T) {
+	registered := &mockResolverBuilder{scheme: "child-test"}
+	resolver.Register(registered)
+	defer resolver.Unregister(registered.scheme)
+
+	// the builders of a ClientConn looking them up are used instead of the registered ones,
+	// the ClientConns of the children look them up like their parent
+	own := &mockResolverBuilder{scheme: "child-test", addresses: []string{"127.0.0.1:8000"}}
+	parent := &lookupClientConn{registry: resolver.NewRegistry()}
+	parent.registry.Register(own)
+	cc := &ClientConn{Index: 0, UpdateFunc: func(int, resolver.State) {}, ReportFunc: func(int, error) {}, Parent: parent}
+	if _, err := Build("child-test://127.0.0.1:8500/account", cc, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(own.ccs) != 1 || len(registered.ccs) != 0 {
+		t.Fatalf("expect:the builder of the parent used,but get:%d own and %d registered builds", len(own.ccs), len(registered.ccs))
+	}
+	if _, err := Build("child-test://127.0.0.1:8500/account", &ClientConn{Parent: &lookupClientConn{registry: resolver.NewRegistry()}}, nil); err != resolver.ResolverNotExistErr {
+		t.Fatalf("expect:%v,but get:%v", resolver.ResolverNotExistErr, err)
+	}
+}
+
+func Test_BuildErrors(t *testing.T) {
+	if _, err := Build("unknown://127.0.0.1:8500/account", &mockClientConn{}, nil); err != resolver.ResolverNotExistErr {
+		t.Fatalf("expect:%v,but get:%v", resolver.ResolverNotExistErr, err)
+	}
+	if _, err := Build("consul://127.0.0.1:8500/%zz", &mockClientConn{}, nil); err == nil {
+		t.Fatal("expect:an error for a malformed target")
+	}
+}
+
+func Test_ClientConn(t *testing.T) {
+	var indexes []int
+	var errs []error
+	cc := &ClientConn{
+		Index: 2,
+		UpdateFunc: func(index int, state resolver.State) {
+			indexes = append(indexes, index)
+		},
+		ReportFunc: func(index int, err error) {
+			indexes = append(indexes, index)
+			errs = append(errs, err)
+		},
+	}
+	cc.UpdateState(resolver.State{})
+	cc.ReportError(errors.New("consul unreachable"))
+	if len(indexes) != 2 || indexes[0] != 2 || indexes[1] != 2 || len(errs) != 1 {
+		t.Fatalf("expect:index 2 for both updates,but get:%v %v", indexes, errs)
+	}
+}
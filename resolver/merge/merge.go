@@ -0,0 +1,177 @@
+// Package merge implements a resolver which unions the addresses resolved from several targets,
+// such as the same Consul service in two datacenters.
+package merge
+
+import (
+	"sync"
+
+	"github.com/classtorch/prpc/resolver"
+	"github.com/classtorch/prpc/resolver/internal/child"
+)
+
+// schemeName for the urls
+// All target URLs like 'merge:///service' will be resolved by this resolver
+const schemeName = "merge"
+
+// SourceKey is the Address.Attributes key of the target an address was resolved from
+type SourceKey struct{}
+
+// Source return the target an address was resolved from
+func Source(addr resolver.Address) (string, bool) {
+	source, ok := addr.Attributes[SourceKey{}].(string)
+	return source, ok
+}
+
+// NewResolverBuilder new a resolver builder resolving all targets,
+// builders are used before the resolvers of the ClientConn to resolve them.
+func NewResolverBuilder(targets []string, builders ...resolver.Builder) resolver.Builder {
+	return &builder{
+		targets:  targets,
+		builders: builders,
+	}
+}
+
+// builder implements resolver.Builder
+type builder struct {
+	targets  []string
+	builders []resolver.Builder
+}
+
+// Build build a merge resolver, the endpoint of target is ignored
+func (b *builder) Build(target resolver.Target, cc resolver.ClientConn) (resolver.Resolver, error) {
+	r := &Resolver{
+		cc:       cc,
+		targets:  b.targets,
+		children: make([]resolver.Resolver, len(b.targets)),
+		states:   make([]childState, len(b.targets)),
+	}
+	for i, t := range b.targets {
+		childResolver, err := child.Build(t, &child.ClientConn{Index: i, UpdateFunc: r.updateState, ReportFunc: r.reportError, Parent: cc}, b.builders)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.mu.Lock()
+		r.children[i] = childResolver
+		r.mu.Unlock()
+	}
+	return r, nil
+}
+
+// Scheme returns the scheme supported by this resolver.
+func (b *builder) Scheme() string {
+	return schemeName
+}
+
+// childState latest state of a child resolver, on error the last addresses are kept
+type childState struct {
+	addresses []resolver.Address
+	err       error
+}
+
+// Resolver implements resolver.Resolver
+type Resolver struct {
+	cc       resolver.ClientConn
+	targets  []string
+	mu       sync.Mutex
+	children []resolver.Resolver
+	states   []childState
+	// publishing is set while a caller publishes the states, dirty when they changed since its last publish
+	publishing bool
+	dirty      bool
+}
+
+func (r *Resolver) updateState(index int, state resolver.State) {
+	r.mu.Lock()
+	addresses := make([]resolver.Address, len(state.Addresses))
+	for i, addr := range state.Addresses {
+		attributes := make(map[interface{}]interface{}, len(addr.Attributes)+1)
+		for k, v := range addr.Attributes {
+			attributes[k] = v
+		}
+		attributes[SourceKey{}] = r.targets[index]
+		addr.Attributes = attributes
+		addresses[i] = addr
+	}
+	r.states[index] = childState{addresses: addresses}
+	r.publish()
+}
+
+func (r *Resolver) reportError(index int, err error) {
+	r.mu.Lock()
+	r.states[index].err = err
+	r.publish()
+}
+
+// publish push the latest states to cc, it is called with mu held and releases it.
+// cc is called without mu by one caller at a time, the states changed meanwhile, e.g. by cc
+// calling ResolveNow, are pushed by the same caller once cc returns.
+func (r *Resolver) publish() {
+	r.dirty = true
+	if r.publishing {
+		r.mu.Unlock()
+		return
+	}
+	r.publishing = true
+	for r.dirty {
+		r.dirty = false
+		state, err := r.current()
+		r.mu.Unlock()
+		if err != nil {
+			r.cc.ReportError(err)
+		} else {
+			r.cc.UpdateState(state)
+		}
+		r.mu.Lock()
+	}
+	r.publishing = false
+	r.mu.Unlock()
+}
+
+// current return the union of all targets addresses, an address resolved by several targets
+// is tagged with the first one. The error is returned only when there are no addresses at all.
+func (r *Resolver) current() (resolver.State, error) {
+	var addresses []resolver.Address
+	var firstErr error
+	seen := make(map[string]bool)
+	for _, s := range r.states {
+		if s.err != nil && firstErr == nil {
+			firstErr = s.err
+		}
+		for _, addr := range s.addresses {
+			if seen[addr.Addr] {
+				continue
+			}
+			seen[addr.Addr] = true
+			addresses = append(addresses, addr)
+		}
+	}
+	if len(addresses) == 0 && firstErr != nil {
+		return resolver.State{}, firstErr
+	}
+	return resolver.State{Addresses: addresses}, nil
+}
+
+// ResolveNow forwards ResolveNow to all targets
+func (r *Resolver) ResolveNow() {
+	r.mu.Lock()
+	children := r.children
+	r.mu.Unlock()
+	for _, c := range children {
+		if c != nil {
+			c.ResolveNow()
+		}
+	}
+}
+
+// Close closes all targets resolvers
+func (r *Resolver) Close() {
+	r.mu.Lock()
+	children := r.children
+	r.mu.Unlock()
+	for _, c := range children {
+		if c != nil {
+			c.Close()
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package resolver
+
+import (
+	"sync"
+)
+
+var (
+	defaultRegistry = NewRegistry()
+)
+
+// Registry is a set of resolver Builders indexed by scheme, it is safe for concurrent use.
+// A Registry can be passed to a ClientConn to isolate its resolvers from the global ones.
+type Registry struct {
+	mu sync.RWMutex
+	m  map[string]Builder
+}
+
+// NewRegistry return an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{m: make(map[string]Builder)}
+}
+
+// DefaultRegistry return the global Registry used by Register, Unregister and Get
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// Register register a resolver Builder, a Builder with the same scheme is replaced
+func (r *Registry) Register(b Builder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.m[b.Scheme()] = b
+}
+
+// Unregister remove the resolver Builder of scheme
+func (r *Registry) Unregister(scheme string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.m, scheme)
+}
+
+// Get get a resolver Builder by scheme, return nil if not exist
+func (r *Registry) Get(scheme string) Builder {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if b, ok := r.m[scheme]; ok {
+		return b
+	}
+	return nil
+}
+
+// BuilderLookup is implemented by the ClientConns looking up the resolver Builders by themselves,
+// e.g. in the Registry given to the pRPC ClientConn, meta-resolvers build their children with it
+type BuilderLookup interface {
+	ResolverBuilder(scheme string) Builder
+}
+
+// LookupBuilder return the resolver Builder of scheme looked up by cc if it is a BuilderLookup,
+// otherwise the one of the default Registry
+func LookupBuilder(cc ClientConn, scheme string) Builder {
+	if lookup, ok := cc.(BuilderLookup); ok {
+		return lookup.ResolverBuilder(scheme)
+	}
+	return Get(scheme)
+}
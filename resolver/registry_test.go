@@ -0,0 +1,73 @@
+package resolver
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// schemeBuilder a Builder of scheme building nothing
+type schemeBuilder string
+
+func (b schemeBuilder) Build(target Target, cc ClientConn) (Resolver, error) {
+	return nil, nil
+}
+
+func (b schemeBuilder) Scheme() string {
+	return string(b)
+}
+
+func Test_Registry(t *testing.T) {
+	r := NewRegistry()
+	if b := r.Get("consul"); b != nil {
+		t.Fatalf("expect:nil,but get:%v", b)
+	}
+	r.Register(schemeBuilder("consul"))
+	if b := r.Get("consul"); b != schemeBuilder("consul") {
+		t.Fatalf("expect:consul,but get:%v", b)
+	}
+	r.Unregister("consul")
+	if b := r.Get("consul"); b != nil {
+		t.Fatalf("expect:nil after Unregister,but get:%v", b)
+	}
+}
+
+func Test_RegistryIsolation(t *testing.T) {
+	a, b := NewRegistry(), NewRegistry()
+	a.Register(schemeBuilder("registry-a"))
+	if got := b.Get("registry-a"); got != nil {
+		t.Fatalf("expect:nil in the other registry,but get:%v", got)
+	}
+	if got := Get("registry-a"); got != nil {
+		t.Fatalf("expect:nil in the default registry,but get:%v", got)
+	}
+	Register(schemeBuilder("registry-default"))
+	defer Unregister("registry-default")
+	if got := a.Get("registry-default"); got != nil {
+		t.Fatalf("expect:nil in a new registry,but get:%v", got)
+	}
+	if got := DefaultRegistry().Get("registry-default"); got != schemeBuilder("registry-default") {
+		t.Fatalf("expect:registry-default,but get:%v", got)
+	}
+}
+
+func Test_RegistryConcurrency(t *testing.T) {
+	r := NewRegistry()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			scheme := "scheme" + strconv.Itoa(i)
+			for j := 0; j < 100; j++ {
+				r.Register(schemeBuilder(scheme))
+				if b := r.Get(scheme); b == nil {
+					t.Errorf("expect:%s,but get:nil", scheme)
+				}
+				r.Get("scheme0")
+				r.Unregister(scheme)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -2,13 +2,14 @@ package resolver
 
 import (
 	"errors"
+	"fmt"
 	toolString "github.com/classtorch/prpc/pkg/strings"
 	"google.golang.org/grpc/attributes"
+	"net/url"
 	"strings"
 )
 
 var (
-	m           = make(map[string]Builder)
 	passThrough = "pass_through"
 )
 
@@ -16,17 +17,19 @@ var (
 	ResolverNotExistErr = errors.New("scheme resolver not exist")
 )
 
-// Register register a resolver Builder
+// Register register a resolver Builder to the default Registry
 func Register(b Builder) {
-	m[b.Scheme()] = b
+	defaultRegistry.Register(b)
 }
 
-// Get get a resolver Builder by scheme
+// Unregister remove a resolver Builder from the default Registry
+func Unregister(scheme string) {
+	defaultRegistry.Unregister(scheme)
+}
+
+// Get get a resolver Builder by scheme from the default Registry
 func Get(scheme string) Builder {
-	if b, ok := m[scheme]; ok {
-		return b
-	}
-	return nil
+	return defaultRegistry.Get(scheme)
 }
 
 // GetPassThroughScheme get passThrough flag
@@ -82,14 +85,25 @@ type Resolver interface {
 	Close()
 }
 
-// Target target such as "consul://127.0.0.1:8500/uclass-account"
+// Target target such as "consul://127.0.0.1:8500/uclass-account?dc=dc1"
 // consul->schema
 // 127.0.0.1:8500->agent
 // uclass-account->endpoint
+// dc=dc1->query
+//
+// It follows the gRPC naming spec https://github.com/grpc/grpc/blob/master/doc/naming.md,
+// a target without "://" is a pass through target except for the "unix:path" and
+// "unix-abstract:name" forms.
 type Target struct {
 	Scheme   string
 	Agent    string
 	Endpoint string
+	// URL is the whole parsed target, only URL.Path is set for a pass through target.
+	// Resolvers needing the raw path, such as "unix:///tmp/sock", should use URL.Path
+	// since Endpoint has its leading "/" removed.
+	URL url.URL
+	// Query is the parsed query of the target
+	Query url.Values
 }
 
 // ClientConn
@@ -101,24 +115,38 @@ type ClientConn interface {
 	ReportError(error)
 }
 
-// ParseTarget convert string target to Target struct
-func ParseTarget(target string) Target {
-	var scheme = passThrough
-	endpoint := ""
-	agent := ""
-	schemeIndex := strings.Index(target, "://")
-	ok := false
-	if schemeIndex > 0 {
-		scheme, endpoint, ok = toolString.Split2(target, "://")
-		if !ok {
-			return Target{Endpoint: target}
-		}
-		agent, endpoint, ok = toolString.Split2(endpoint, "/")
-		if !ok {
-			return Target{Scheme: scheme, Endpoint: target}
+// opaqueSchemes schemes accepted in the "scheme:path" form
+var opaqueSchemes = map[string]bool{
+	"unix":          true,
+	"unix-abstract": true,
+}
+
+// ParseTarget convert string target to Target struct, return an error if the target is malformed
+func ParseTarget(target string) (Target, error) {
+	if !strings.Contains(target, "://") {
+		scheme, _, ok := toolString.Split2(target, ":")
+		if !ok || !opaqueSchemes[scheme] {
+			return Target{Scheme: passThrough, Endpoint: target, URL: url.URL{Path: target}}, nil
 		}
 	}
-	return Target{Scheme: scheme, Agent: agent, Endpoint: endpoint}
+	u, err := url.Parse(target)
+	if err != nil {
+		return Target{}, fmt.Errorf("invalid target %q: %v", target, err)
+	}
+	if u.Scheme == "" {
+		return Target{}, fmt.Errorf("invalid target %q: missing scheme", target)
+	}
+	endpoint := u.Opaque
+	if endpoint == "" {
+		endpoint = strings.TrimPrefix(u.Path, "/")
+	}
+	return Target{
+		Scheme:   u.Scheme,
+		Agent:    u.Host,
+		Endpoint: endpoint,
+		URL:      *u,
+		Query:    u.Query(),
+	}, nil
 }
 
 // ClientConnResolverInterface
@@ -0,0 +1,49 @@
+package resolver
+
+import (
+	"testing"
+)
+
+func Test_ParseTarget(t *testing.T) {
+	testCases := []struct {
+		target    string
+		expectErr bool
+		scheme    string
+		agent     string
+		endpoint  string
+		path      string
+		query     string
+	}{
+		{target: "consul://127.0.0.1:8500/uclass-account", scheme: "consul", agent: "127.0.0.1:8500", endpoint: "uclass-account", path: "/uclass-account"},
+		{target: "consul://127.0.0.1:8500/uclass-account?dc=dc1&healthy=true", scheme: "consul", agent: "127.0.0.1:8500", endpoint: "uclass-account", path: "/uclass-account", query: "dc=dc1&healthy=true"},
+		{target: "consul:///svc", scheme: "consul", endpoint: "svc", path: "/svc"},
+		{target: "dns:///host:53?x=y", scheme: "dns", endpoint: "host:53", path: "/host:53", query: "x=y"},
+		{target: "dns://8.8.8.8/www.google.com:443", scheme: "dns", agent: "8.8.8.8", endpoint: "www.google.com:443", path: "/www.google.com:443"},
+		{target: "unix:///tmp/sock", scheme: "unix", endpoint: "tmp/sock", path: "/tmp/sock"},
+		{target: "unix:relative/sock", scheme: "unix", endpoint: "relative/sock"},
+		{target: "unix-abstract:name", scheme: "unix-abstract", endpoint: "name"},
+		{target: "consul://[::1]:8500/svc", scheme: "consul", agent: "[::1]:8500", endpoint: "svc", path: "/svc"},
+		{target: "[::1]:8080", scheme: passThrough, endpoint: "[::1]:8080", path: "[::1]:8080"},
+		{target: "127.0.0.1:8000/account", scheme: passThrough, endpoint: "127.0.0.1:8000/account", path: "127.0.0.1:8000/account"},
+		{target: "localhost:8080", scheme: passThrough, endpoint: "localhost:8080", path: "localhost:8080"},
+		{target: "consul://127.0.0.1:port/svc", expectErr: true},
+		{target: "consul://[::1/svc", expectErr: true},
+		{target: "://127.0.0.1/svc", expectErr: true},
+	}
+	for _, tCase := range testCases {
+		target, err := ParseTarget(tCase.target)
+		if tCase.expectErr {
+			if err == nil {
+				t.Fatalf("target:%s expect err but get nil", tCase.target)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("target:%s unexpected err:%v", tCase.target, err)
+		}
+		if target.Scheme != tCase.scheme || target.Agent != tCase.agent || target.Endpoint != tCase.endpoint ||
+			target.URL.Path != tCase.path || target.Query.Encode() != tCase.query {
+			t.Fatalf("target:%s get unexpected result:%+v", tCase.target, target)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package wrapper
 
 import (
 	"github.com/classtorch/prpc/balancer"
+	"github.com/classtorch/prpc/balancer/base"
 	"github.com/classtorch/prpc/resolver"
 	"sync"
 )
@@ -10,6 +11,7 @@ import (
 type CCBalancerWrapper struct {
 	pickWrapper *PickerWrapper
 	balancer    balancer.Balancer
+	curState    resolver.State
 	mu          sync.Mutex
 }
 
@@ -29,6 +31,21 @@ func (ccb *CCBalancerWrapper) close() {
 func (ccb *CCBalancerWrapper) UpdateState(state resolver.State) {
 	ccb.mu.Lock()
 	defer ccb.mu.Unlock()
+	ccb.curState = state
 	newPicker, _ := ccb.balancer.UpdateState(state)
 	ccb.pickWrapper.updatePicker(newPicker)
+	ccb.pickWrapper.recordReadyAddresses(len(state.Addresses))
+}
+
+// ResolverError is called when the resolver reports an error.
+// The last known good addresses keep being used, only when there are none
+// the picker is replaced by one failing every pick with err.
+func (ccb *CCBalancerWrapper) ResolverError(err error) {
+	ccb.mu.Lock()
+	defer ccb.mu.Unlock()
+	if len(ccb.curState.Addresses) > 0 {
+		return
+	}
+	ccb.pickWrapper.updatePicker(base.NewErrPicker(err))
+	ccb.pickWrapper.recordReadyAddresses(0)
 }
@@ -12,16 +12,24 @@ type ConnWrapper struct {
 	PickerWrapper   *PickerWrapper
 }
 
-func GetBalancerWrapper(curBalancerName string, pickerWrapper *PickerWrapper) (*CCBalancerWrapper, error) {
+// GetBalancerWrapper build the balancer named curBalancerName from registry,
+// the default Registry is used when registry is nil and round robin when curBalancerName is empty
+func GetBalancerWrapper(registry *balancer.Registry, curBalancerName string, pickerWrapper *PickerWrapper) (*CCBalancerWrapper, error) {
+	if registry == nil {
+		registry = balancer.DefaultRegistry()
+	}
 	var balancerBuilder balancer.Builder
 	if len(curBalancerName) > 0 {
-		balancerBuilder = balancer.Get(curBalancerName)
+		balancerBuilder = registry.Get(curBalancerName)
 		if balancerBuilder == nil {
 			return nil, balancer.BalancerNotExistErr
 		}
 	}
 	if balancerBuilder == nil {
-		balancerBuilder = balancer.Get(roundrobin.Name)
+		balancerBuilder = registry.Get(roundrobin.Name)
+	}
+	if balancerBuilder == nil {
+		balancerBuilder = roundrobin.NewBalancerBuild()
 	}
 	return NewCCBalancerWrapper(pickerWrapper, balancerBuilder), nil
 }
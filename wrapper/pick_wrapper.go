@@ -2,29 +2,141 @@ package wrapper
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"github.com/classtorch/prpc/balancer"
-	logger2 "github.com/classtorch/prpc/logger"
+	"github.com/classtorch/prpc/logger"
+	"github.com/classtorch/prpc/metrics"
+	"github.com/classtorch/prpc/resolver"
 	"sync"
+	"time"
 )
 
+// PickTrace hooks called when an address is picked for a call whose context carries it, see WithPickTrace.
+// A nil hook is not called.
+type PickTrace struct {
+	// PickerUpdated is called when the picker is updated while the pick waits for one, e.g. the resolver found addresses
+	PickerUpdated func()
+	// Picked is called once the pick returns, wait is the time spent in the pick
+	Picked func(address resolver.Address, wait time.Duration, err error)
+}
+
+type pickTraceKey struct{}
+
+// WithPickTrace return a copy of ctx carrying trace, the hooks of the PickTrace already carried by ctx are called first
+func WithPickTrace(ctx context.Context, trace *PickTrace) context.Context {
+	if old := ContextPickTrace(ctx); old != nil {
+		trace = composePickTrace(old, trace)
+	}
+	return context.WithValue(ctx, pickTraceKey{}, trace)
+}
+
+// composePickTrace return a PickTrace calling the hooks of first then the ones of second
+func composePickTrace(first, second *PickTrace) *PickTrace {
+	return &PickTrace{
+		PickerUpdated: func() {
+			if first.PickerUpdated != nil {
+				first.PickerUpdated()
+			}
+			if second.PickerUpdated != nil {
+				second.PickerUpdated()
+			}
+		},
+		Picked: func(address resolver.Address, wait time.Duration, err error) {
+			if first.Picked != nil {
+				first.Picked(address, wait, err)
+			}
+			if second.Picked != nil {
+				second.Picked(address, wait, err)
+			}
+		},
+	}
+}
+
+// ContextPickTrace return the PickTrace of ctx, nil if there is none
+func ContextPickTrace(ctx context.Context) *PickTrace {
+	trace, _ := ctx.Value(pickTraceKey{}).(*PickTrace)
+	return trace
+}
+
+// PickRecord a pick done before the hooks of the call can be installed, e.g. the http ClientConn picks the
+// address before running the interceptors, it is given to them in the context, see ContextPickRecord
+type PickRecord struct {
+	Start         time.Time
+	PickerUpdates []time.Time
+	Address       resolver.Address
+	Wait          time.Duration
+	Err           error
+}
+
+type pickRecordKey struct{}
+
+// NewPickRecord return a PickRecord starting now
+func NewPickRecord() *PickRecord {
+	return &PickRecord{Start: time.Now()}
+}
+
+// Trace return a PickTrace filling the record
+func (r *PickRecord) Trace() *PickTrace {
+	return &PickTrace{
+		PickerUpdated: func() {
+			r.PickerUpdates = append(r.PickerUpdates, time.Now())
+		},
+		Picked: func(address resolver.Address, wait time.Duration, err error) {
+			r.Address, r.Wait, r.Err = address, wait, err
+		},
+	}
+}
+
+// WithPickRecord return a copy of ctx carrying record
+func WithPickRecord(ctx context.Context, record *PickRecord) context.Context {
+	return context.WithValue(ctx, pickRecordKey{}, record)
+}
+
+// ContextPickRecord return the PickRecord of ctx, nil if there is none
+func ContextPickRecord(ctx context.Context) *PickRecord {
+	record, _ := ctx.Value(pickRecordKey{}).(*PickRecord)
+	return record
+}
+
 // PickerWrapper is a wrapper of balancer.Picker. It blocks on certain Pick
 // actions and unblock when there's a picker update.
 type PickerWrapper struct {
 	mu         sync.Mutex
 	blockingCh chan struct{}
 	picker     balancer.Picker
-	log        logger2.Log
+	log        logger.Logger
+	// metrics of the picks of target
+	target  string
+	metrics metrics.MetricsRecorder
+}
+
+// NewPickerWrapper return a pickerWrapper logging to log
+func NewPickerWrapper(log logger.Log) *PickerWrapper {
+	return NewPickerWrapperWithLogger(logger.FromLog(log))
 }
 
-// NewPickerWrapper return a pickerWrapper
-func NewPickerWrapper(log logger2.Log) *PickerWrapper {
+// NewPickerWrapperWithLogger return a pickerWrapper logging to the structured logger log
+func NewPickerWrapperWithLogger(log logger.Logger) *PickerWrapper {
 	return &PickerWrapper{
 		blockingCh: make(chan struct{}),
 		log:        log,
 	}
 }
 
+// SetMetricsRecorder record the picks and the ready addresses of target to recorder,
+// it must be called before the PickerWrapper is used
+func (pw *PickerWrapper) SetMetricsRecorder(target string, recorder metrics.MetricsRecorder) {
+	pw.target = target
+	pw.metrics = recorder
+}
+
+// recordReadyAddresses record the number of addresses of the picker
+func (pw *PickerWrapper) recordReadyAddresses(count int) {
+	if pw.metrics != nil {
+		pw.metrics.ReadyAddresses(pw.target, count)
+	}
+}
+
 // updatePicker update current picker
 func (pw *PickerWrapper) updatePicker(p balancer.Picker) {
 	pw.mu.Lock()
@@ -36,6 +148,28 @@ func (pw *PickerWrapper) updatePicker(p balancer.Picker) {
 
 // Pick pick a available addresses
 func (pw *PickerWrapper) Pick(ctx context.Context, failfast bool) (string, error) {
+	address, err := pw.PickAddress(ctx, failfast)
+	if err != nil {
+		return "", err
+	}
+	return address.Addr, nil
+}
+
+// PickAddress pick a available address, including its ServerName and Attributes
+func (pw *PickerWrapper) PickAddress(ctx context.Context, failfast bool) (address resolver.Address, err error) {
+	trace := ContextPickTrace(ctx)
+	if (trace != nil && trace.Picked != nil) || pw.metrics != nil {
+		start := time.Now()
+		defer func() {
+			wait := time.Since(start)
+			if pw.metrics != nil {
+				pw.metrics.PickWait(pw.target, wait, err)
+			}
+			if trace != nil && trace.Picked != nil {
+				trace.Picked(address, wait, err)
+			}
+		}()
+	}
 	var ch chan struct{}
 
 	var lastPickErr error
@@ -48,14 +182,15 @@ func (pw *PickerWrapper) Pick(ctx context.Context, failfast bool) (string, error
 			pw.mu.Unlock()
 			select {
 			case <-ctx.Done():
-				var errStr string
+				// the error wraps the context one, errors.Is(err, context.DeadlineExceeded) holds once the deadline is exceeded
 				if lastPickErr != nil {
-					errStr = "latest balancer error: " + lastPickErr.Error()
-				} else {
-					errStr = ctx.Err().Error()
+					return resolver.Address{}, fmt.Errorf("%w, latest balancer error: %v", ctx.Err(), lastPickErr)
 				}
-				return "", errors.New(errStr)
+				return resolver.Address{}, ctx.Err()
 			case <-ch:
+				if trace != nil && trace.PickerUpdated != nil {
+					trace.PickerUpdated()
+				}
 			}
 			continue
 		}
@@ -67,17 +202,19 @@ func (pw *PickerWrapper) Pick(ctx context.Context, failfast bool) (string, error
 		pickResult, err := p.Pick()
 
 		if err != nil {
+			// the picks which are not failfast wait for the next picker, e.g. the resolver finds addresses
+			// again after reporting an error, and return the latest error once ctx is done
 			if !failfast {
 				lastPickErr = err
-				pw.log.Errorf("pick err:%+v,rePicking...", err)
+				pw.log.Debug("pick error, waiting for a picker update", "err", err)
 				continue
 			}
-			return "", err
+			return resolver.Address{}, err
 		}
 		if pickResult.Done != nil {
 			pickResult.Done(balancer.DoneInfo{})
 		}
-		return pickResult.Address.Addr, nil
+		return pickResult.Address, nil
 	}
 }
 
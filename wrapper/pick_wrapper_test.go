@@ -0,0 +1,76 @@
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"github.com/classtorch/prpc/logger"
+	"github.com/classtorch/prpc/resolver"
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_PickResolverError(t *testing.T) {
+	pickerWrapper := NewPickerWrapperWithLogger(logger.NopLogger{})
+	balancerWrapper, err := GetBalancerWrapper(nil, "", pickerWrapper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolverErr := errors.New("consul unavailable")
+	balancerWrapper.ResolverError(resolverErr)
+
+	// the failfast picks return the resolver error
+	if _, err = pickerWrapper.Pick(context.Background(), true); err != resolverErr {
+		t.Fatalf("expect:%v,but get:%v", resolverErr, err)
+	}
+
+	// the other picks wait until their context is done, the error carries the resolver one
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = pickerWrapper.Pick(ctx, false)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), resolverErr.Error()) {
+		t.Fatalf("expect:%v carrying %v,but get:%v", context.DeadlineExceeded, resolverErr, err)
+	}
+
+	// or until the resolver finds addresses again
+	picked := make(chan string, 1)
+	go func() {
+		addr, err := pickerWrapper.Pick(context.Background(), false)
+		if err != nil {
+			addr = err.Error()
+		}
+		picked <- addr
+	}()
+	time.Sleep(20 * time.Millisecond)
+	balancerWrapper.ResolverError(resolverErr)
+	balancerWrapper.UpdateState(resolver.State{Addresses: []resolver.Address{{Addr: "127.0.0.1:8000"}}})
+	select {
+	case addr := <-picked:
+		if addr != "127.0.0.1:8000" {
+			t.Fatalf("expect:%v,but get:%v", "127.0.0.1:8000", addr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect:the pick to return once the resolver found addresses")
+	}
+
+	// with known addresses the resolver errors don't fail the picks
+	balancerWrapper.ResolverError(resolverErr)
+	if addr, err := pickerWrapper.Pick(context.Background(), true); err != nil || addr != "127.0.0.1:8000" {
+		t.Fatalf("expect:%v,but get:%v, err:%v", "127.0.0.1:8000", addr, err)
+	}
+}
+
+func Test_NewPickerWrapperLog(t *testing.T) {
+	// the Log of the former NewPickerWrapper signature is still accepted
+	pickerWrapper := NewPickerWrapper(logger.NewDefaultLogger(log.New(ioutil.Discard, "", 0)))
+	balancerWrapper, err := GetBalancerWrapper(nil, "", pickerWrapper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	balancerWrapper.UpdateState(resolver.State{Addresses: []resolver.Address{{Addr: "127.0.0.1:8000"}}})
+	if addr, err := pickerWrapper.Pick(context.Background(), true); err != nil || addr != "127.0.0.1:8000" {
+		t.Fatalf("expect:%v,but get:%v, err:%v", "127.0.0.1:8000", addr, err)
+	}
+}
@@ -79,6 +79,14 @@ func (ccr *CCResolverWrapper) UpdateState(s resolver.State) error {
 	return nil
 }
 
+// ResolverBuilder look up the resolver Builder of scheme like cc, if it is a resolver.BuilderLookup
+func (ccr *CCResolverWrapper) ResolverBuilder(scheme string) resolver.Builder {
+	if lookup, ok := ccr.cc.(resolver.BuilderLookup); ok {
+		return lookup.ResolverBuilder(scheme)
+	}
+	return resolver.Get(scheme)
+}
+
 // ReportError report a error
 func (ccr *CCResolverWrapper) ReportError(err error) {
 	ccr.incomingMu.Lock()